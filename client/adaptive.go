@@ -0,0 +1,65 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyWeight is the weight of the latest latency in the moving average of the adaptive backoff.
+const latencyWeight = 0.2
+
+// WithAdaptiveBackoff bases the wait between attempts on the latency recently observed from the
+// upstream, rather than on the interval: the exponentially weighted moving average of the
+// latencies of the responses replaces the interval in the backoff strategy, so a slow upstream
+// is given more time to recover than a fast one. The wait stays within the bounds set with
+// WithMinInterval and WithMaxBackoff. Until a response is received, the interval applies.
+func (r *RestClient) WithAdaptiveBackoff() *RestClient {
+	r.latency = &latencyTracker{}
+	return r
+}
+
+// latencyTracker keeps the exponentially weighted moving average of the observed latencies.
+type latencyTracker struct {
+	mu      sync.Mutex
+	average time.Duration
+	seen    bool
+}
+
+// observe adds the latency to the moving average, which starts from the first one.
+func (l *latencyTracker) observe(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.seen {
+		l.average, l.seen = latency, true
+		return
+	}
+	l.average = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(l.average))
+}
+
+// ewma returns the moving average of the observed latencies, false when none was observed yet.
+func (l *latencyTracker) ewma() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.average, l.seen
+}
+
+// observeLatency records the latency of an attempt for the adaptive backoff, when enabled. Attempts
+// without a response, e.g. refused connections, say nothing about the upstream and are left out.
+func (r *RestClient) observeLatency(status int64, started time.Time) {
+	if r.latency == nil || status == internalStatusRequestError {
+		return
+	}
+	r.latency.observe(r.now().Sub(started))
+}
+
+// adapt replaces the interval of the policy with the moving average of the observed latencies,
+// when the adaptive backoff is enabled and a latency was observed.
+func (r *RestClient) adapt(policy RetryPolicy) RetryPolicy {
+	if r.latency == nil {
+		return policy
+	}
+	if average, ok := r.latency.ewma(); ok {
+		policy.IntervalSeconds = average.Seconds()
+	}
+	return policy
+}
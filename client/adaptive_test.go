@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mauriciozanettisalomao/go-rest-client/client/clienttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoAdaptiveBackoff(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		adaptive       bool
+		maxBackoff     time.Duration
+		minInterval    time.Duration
+		latencies      []time.Duration
+		expectedSleeps []time.Duration
+	}{
+		{
+			name:      "tracks the moving average of the latencies",
+			adaptive:  true,
+			latencies: []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 200 * time.Millisecond, 0},
+			// averages of 100ms, 0.2*300+0.8*100 = 140ms and 0.2*200+0.8*140 = 152ms, doubled on every retry
+			expectedSleeps: []time.Duration{200 * time.Millisecond, 560 * time.Millisecond, 1216 * time.Millisecond},
+		},
+		{
+			name:           "capped by the max backoff",
+			adaptive:       true,
+			maxBackoff:     500 * time.Millisecond,
+			latencies:      []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 200 * time.Millisecond, 0},
+			expectedSleeps: []time.Duration{200 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name:           "raised to the min interval",
+			adaptive:       true,
+			minInterval:    time.Second,
+			latencies:      []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 200 * time.Millisecond, 0},
+			expectedSleeps: []time.Duration{time.Second, time.Second, 1216 * time.Millisecond},
+		},
+		{
+			name:           "off by default",
+			latencies:      []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 200 * time.Millisecond, 0},
+			expectedSleeps: []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			clock := clienttest.NewFakeClock(time.Now())
+			var calls int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(tc.latencies[calls])
+				calls++
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, `{"message": "unavailable"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(4).
+				WithIntervalSeconds(1).
+				WithBackoffRate(2).
+				WithMaxBackoff(tc.maxBackoff).
+				WithMinInterval(tc.minInterval).
+				WithClock(clock)
+			if tc.adaptive {
+				m.WithAdaptiveBackoff()
+			}
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.Error(err)
+			assertion.Equal(4, calls)
+			sleeps := clock.Sleeps()
+			if assertion.Len(sleeps, len(tc.expectedSleeps)) {
+				for i, expected := range tc.expectedSleeps {
+					assertion.InDelta(expected, sleeps[i], float64(time.Microsecond))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ContextKey is the type of the keys of the values the client sets on the context of every attempt,
+// for the hooks and tracing spans to read.
+type ContextKey string
+
+const (
+	// AttemptContextKey carries the number of the attempt, an int64 counted from 1.
+	AttemptContextKey ContextKey = "attempt"
+	// RetryReasonContextKey carries the RetryReason the attempt is made for, on retries only.
+	RetryReasonContextKey ContextKey = "retry_reason"
+)
+
+// AttemptFromContext returns the number of the attempt set on the context by the client, counted from 1.
+func AttemptFromContext(ctx context.Context) (int64, bool) {
+	attempt, ok := ctx.Value(AttemptContextKey).(int64)
+	return attempt, ok
+}
+
+// RetryReasonFromContext returns the reason of the retry set on the context by the client,
+// reporting false on first attempts.
+func RetryReasonFromContext(ctx context.Context) (RetryReason, bool) {
+	reason, ok := ctx.Value(RetryReasonContextKey).(RetryReason)
+	return reason, ok
+}
+
+// withAttempt returns a context carrying the number of the attempt, counted from 0, and the
+// reason of the retry when it is not the first one.
+func withAttempt(ctx context.Context, attempt int64, reason RetryReason) context.Context {
+	ctx = context.WithValue(ctx, AttemptContextKey, attempt+1)
+	if attempt > 0 {
+		ctx = context.WithValue(ctx, RetryReasonContextKey, reason)
+	}
+	return ctx
+}
+
+// WithRequestHook sets a hook that runs on the request of every attempt right before it is sent,
+// e.g. to sign it or to start a tracing span. The context of the request carries the number of
+// the attempt and the reason of the retry, read with AttemptFromContext and RetryReasonFromContext.
+// An error fails the attempt.
+func (r *RestClient) WithRequestHook(hook func(req *http.Request) error) *RestClient {
+	r.requestHook = hook
+	return r
+}
+
+// WithRawResponse sets a callback that receives the response of every attempt before its body is
+// read, e.g. to inspect the connection state or the protocol. The client keeps reading, decoding
+// and closing the body, which the callback must leave alone. An error fails the attempt.
+func (r *RestClient) WithRawResponse(callback func(resp *http.Response) error) *RestClient {
+	r.rawResponse = callback
+	return r
+}
+
+// inspectResponse passes the response to the callback set with WithRawResponse, if any.
+func (r *RestClient) inspectResponse(ctx context.Context, resp *http.Response) error {
+	if r.rawResponse == nil {
+		return nil
+	}
+	if err := r.rawResponse(resp); err != nil {
+		err = fmt.Errorf("raw response callback: %w", err)
+		r.logger().ErrorContext(ctx, "raw response callback failed",
+			"err", err,
+			"status", resp.StatusCode,
+		)
+		return err
+	}
+	return nil
+}
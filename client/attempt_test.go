@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoAttemptContextValues(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var calls int
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"message": "success"}`)
+		}
+	}))
+	defer svr.Close()
+
+	var (
+		attempts []int64
+		reasons  []interface{}
+	)
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(3).
+		WithRetryOn(http.StatusTooManyRequests).
+		WithRequestHook(func(req *http.Request) error {
+			attempt, ok := AttemptFromContext(req.Context())
+			assertion.True(ok)
+			attempts = append(attempts, attempt)
+			if reason, ok := RetryReasonFromContext(req.Context()); ok {
+				reasons = append(reasons, reason)
+			} else {
+				reasons = append(reasons, nil)
+			}
+			return nil
+		})
+
+	var result map[string]interface{}
+	status, err := m.Do(context.Background(), nil, &result)
+	assertion.NoError(err)
+	assertion.Equal(int64(200), status)
+	assertion.Equal([]int64{1, 2, 3}, attempts)
+	// the first attempt is not a retry
+	assertion.Equal([]interface{}{nil, RetryServerError, RetryRateLimited}, reasons)
+
+	_, ok := AttemptFromContext(context.Background())
+	assertion.False(ok)
+}
+
+func TestDoRequestHookFailure(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var calls int
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithRequestHook(func(req *http.Request) error {
+			return errors.New("signing failed")
+		})
+
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	assertion.ErrorContains(err, "request hook: signing failed")
+	assertion.Equal(0, calls)
+}
+
+func TestDoRawResponse(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		into           bool
+		status         int
+		callbackErr    error
+		expectedCalls  int
+		expectedStatus []int
+		expectedError  string
+	}{
+		{
+			name:           "raw response observed",
+			status:         http.StatusOK,
+			expectedCalls:  1,
+			expectedStatus: []int{http.StatusOK},
+		},
+		{
+			name:           "error response observed",
+			status:         http.StatusNotFound,
+			expectedCalls:  1,
+			expectedStatus: []int{http.StatusNotFound},
+			expectedError:  "404",
+		},
+		{
+			name:           "callback failure fails the attempt",
+			status:         http.StatusOK,
+			callbackErr:    errors.New("unexpected protocol"),
+			expectedCalls:  2,
+			expectedStatus: []int{http.StatusOK, http.StatusOK},
+			expectedError:  "raw response callback: unexpected protocol",
+		},
+		{
+			name:           "raw response observed by DoInto",
+			into:           true,
+			status:         http.StatusOK,
+			expectedCalls:  1,
+			expectedStatus: []int{http.StatusOK},
+		},
+		{
+			name:           "callback failure fails the attempt of DoInto",
+			into:           true,
+			status:         http.StatusOK,
+			callbackErr:    errors.New("unexpected protocol"),
+			expectedCalls:  2,
+			expectedStatus: []int{http.StatusOK, http.StatusOK},
+			expectedError:  "raw response callback: unexpected protocol",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("X-Upstream", "eu-west-1")
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, `{"message": "done"}`)
+			}))
+			defer svr.Close()
+
+			var (
+				statuses  []int
+				upstreams []string
+			)
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(2).
+				WithRawResponse(func(resp *http.Response) error {
+					statuses = append(statuses, resp.StatusCode)
+					upstreams = append(upstreams, resp.Header.Get("X-Upstream"))
+					assertion.Equal("HTTP/1.1", resp.Proto)
+					return tc.callbackErr
+				})
+
+			var (
+				result map[string]interface{}
+				buf    bytes.Buffer
+				err    error
+			)
+			if tc.into {
+				_, err = m.DoInto(context.Background(), nil, &buf)
+			} else {
+				_, err = m.Do(context.Background(), nil, &result)
+			}
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expectedStatus, statuses)
+			for _, upstream := range upstreams {
+				assertion.Equal("eu-west-1", upstream)
+			}
+			if tc.expectedError != "" {
+				assertion.ErrorContains(err, tc.expectedError)
+				return
+			}
+			assertion.NoError(err)
+			// the client still reads the body
+			if tc.into {
+				assertion.Equal(`{"message": "done"}`, buf.String())
+				return
+			}
+			assertion.Equal(map[string]interface{}{"message": "done"}, result)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultUnhealthyCooldown = 10 * time.Second
+)
+
+// LBStrategy defines how the URL of the first attempt of each call is chosen
+// among the URLs configured with WithURLs.
+type LBStrategy int
+
+const (
+	// Ordered always starts from the first URL.
+	Ordered LBStrategy = iota
+	// RoundRobin starts each call on the URL following the one used by the previous call.
+	RoundRobin
+	// Random starts each call on a randomly chosen URL.
+	Random
+)
+
+// balancer spreads calls across URLs and keeps track of the ones that recently failed.
+type balancer struct {
+	mu        sync.Mutex
+	strategy  LBStrategy
+	next      int
+	cooldown  time.Duration
+	now       func() time.Time
+	unhealthy map[string]time.Time
+}
+
+// start returns the index of the URL to be used on the first attempt of a call.
+func (b *balancer) start(n int, rnd *lockedRand) int {
+	switch b.strategy {
+	case RoundRobin:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		i := b.next % n
+		b.next = i + 1
+		return i
+	case Random:
+		return rnd.Intn(n)
+	default:
+		return 0
+	}
+}
+
+// healthy reports whether the URL has not failed within the cooldown period.
+func (b *balancer) healthy(url string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.unhealthy[url]
+	if !ok {
+		return true
+	}
+	if b.now().After(until) {
+		delete(b.unhealthy, url)
+		return true
+	}
+	return false
+}
+
+// report records the outcome of an attempt against the URL.
+func (b *balancer) report(url string, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		delete(b.unhealthy, url)
+		return
+	}
+	b.unhealthy[url] = b.now().Add(b.cooldown)
+}
+
+func newBalancer(strategy LBStrategy, now func() time.Time) *balancer {
+	return &balancer{
+		strategy:  strategy,
+		cooldown:  defaultUnhealthyCooldown,
+		now:       now,
+		unhealthy: make(map[string]time.Time),
+	}
+}
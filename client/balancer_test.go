@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mauriciozanettisalomao/go-rest-client/client/clienttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoLoadBalancer(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		strategy    LBStrategy
+		calls       int
+		expectedMin []int
+		expectedMax []int
+	}{
+		{
+			name:        "ordered",
+			strategy:    Ordered,
+			calls:       30,
+			expectedMin: []int{30, 0, 0},
+			expectedMax: []int{30, 0, 0},
+		},
+		{
+			name:        "round robin",
+			strategy:    RoundRobin,
+			calls:       30,
+			expectedMin: []int{10, 10, 10},
+			expectedMax: []int{10, 10, 10},
+		},
+		{
+			name:        "random",
+			strategy:    Random,
+			calls:       300,
+			expectedMin: []int{70, 70, 70},
+			expectedMax: []int{130, 130, 130},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			hits := make([]int, 3)
+			urls := make([]string, 3)
+			for i := range hits {
+				i := i
+				svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					hits[i]++
+					fmt.Fprint(w, `{"message": "success"}`)
+				}))
+				defer svr.Close()
+				urls[i] = svr.URL
+			}
+
+			m := NewRestClient().
+				WithURLs(urls...).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithLoadBalancer(tc.strategy).
+				WithSeed(42)
+
+			for i := 0; i < tc.calls; i++ {
+				var result map[string]interface{}
+				status, err := m.Do(context.Background(), nil, &result)
+				assertion.NoError(err)
+				assertion.Equal(int64(200), status)
+			}
+
+			for i := range hits {
+				assertion.GreaterOrEqual(hits[i], tc.expectedMin[i])
+				assertion.LessOrEqual(hits[i], tc.expectedMax[i])
+			}
+		})
+	}
+}
+
+func TestDoLoadBalancerSkipsUnhealthy(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var failingCalls, healthyCalls int
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingCalls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyCalls++
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer healthy.Close()
+
+	m := NewRestClient().
+		WithURLs(failing.URL, healthy.URL).
+		WithMethod("GET").
+		WithMaxAttempts(2).
+		WithLoadBalancer(RoundRobin)
+
+	for i := 0; i < 10; i++ {
+		var result map[string]interface{}
+		status, err := m.Do(context.Background(), nil, &result)
+		assertion.NoError(err)
+		assertion.Equal(int64(200), status)
+	}
+
+	assertion.Equal(1, failingCalls)
+	assertion.Equal(10, healthyCalls)
+}
+
+func TestDoLoadBalancerCooldown(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var failingCalls int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failingCalls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer healthy.Close()
+
+	clock := clienttest.NewFakeClock(time.Now())
+	m := NewRestClient().
+		WithURLs(failing.URL, healthy.URL).
+		WithMethod("GET").
+		WithMaxAttempts(2).
+		WithIntervalSeconds(0).
+		WithClock(clock).
+		WithLoadBalancer(RoundRobin)
+
+	calls := func(n int) {
+		for i := 0; i < n; i++ {
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+		}
+	}
+
+	// the failing URL is skipped for the whole cooldown
+	calls(4)
+	assertion.Equal(int32(1), atomic.LoadInt32(&failingCalls))
+	clock.Advance(defaultUnhealthyCooldown - time.Second)
+	calls(4)
+	assertion.Equal(int32(1), atomic.LoadInt32(&failingCalls))
+
+	// and tried again once it is over
+	clock.Advance(2 * time.Second)
+	calls(2)
+	assertion.Equal(int32(2), atomic.LoadInt32(&failingCalls))
+}
@@ -0,0 +1,388 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// payload is an encoded request body, either buffered or streamed from a reader.
+type payload struct {
+	body        []byte
+	contentType string
+	reader      *streamReader // streamed body, which can only be read once
+	size        int64         // size of the streamed body, -1 when unknown
+}
+
+// streamed reports whether the body is streamed from a reader.
+func (p payload) streamed() bool {
+	return p.reader != nil
+}
+
+// replayable reports whether the body can be sent again by another attempt. Buffered bodies always
+// can, while a streamed body only can when it was provably not sent: the request asked the server to
+// confirm it with an Expect: 100-continue header and not a byte of it was read once the transport was
+// done with it. The transport may be done with the body after returning the response, so it is waited for.
+func (p payload) replayable(ctx context.Context, expectContinue bool) bool {
+	if !p.streamed() {
+		return true
+	}
+	if !expectContinue {
+		return false
+	}
+	if done := p.reader.done; done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return atomic.LoadInt32(&p.reader.consumed) == 0
+}
+
+// track returns the body of a request about to be sent, recording when the transport is done with
+// a streamed body: it closes the body of every request it is given, even on errors.
+func (p payload) track(body io.ReadCloser) io.ReadCloser {
+	if !p.streamed() || body == nil {
+		return body
+	}
+	done := make(chan struct{})
+	p.reader.done = done
+	return &trackedBody{ReadCloser: body, done: done}
+}
+
+// trackedBody is the body of a request closing done once it is closed.
+type trackedBody struct {
+	io.ReadCloser
+	done chan struct{}
+	once sync.Once
+}
+
+func (t *trackedBody) Close() error {
+	err := t.ReadCloser.Close()
+	t.once.Do(func() {
+		close(t.done)
+	})
+	return err
+}
+
+// streamReader is a streamed body recording whether it has been read from.
+type streamReader struct {
+	reader    io.Reader
+	consumed  int32         // set atomically, as the transport reads the body from its own goroutine
+	done      chan struct{} // closed once the transport is done with the body of the last attempt sending it
+	closer    io.Closer     // set when the caller passed an io.ReadCloser, closed once the call is done
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// close closes the streamed body when it was passed as an io.ReadCloser, at most once.
+func (p payload) close() error {
+	if !p.streamed() || p.reader.closer == nil {
+		return nil
+	}
+	p.reader.closeOnce.Do(func() {
+		p.reader.closeErr = p.reader.closer.Close()
+	})
+	return p.reader.closeErr
+}
+
+// maxBufferedReadCloserSize is the size up to which an io.ReadCloser body is read into memory,
+// so that it can be sent again on retries, rather than streamed on a single attempt.
+const maxBufferedReadCloserSize = 1 << 20
+
+// buffer reads an io.ReadCloser body of a known size up to maxBufferedReadCloserSize into memory
+// and closes it. Other bodies are returned as they are.
+func (p payload) buffer() (payload, error) {
+	if !p.streamed() || p.reader.closer == nil || p.size < 0 || p.size > maxBufferedReadCloserSize {
+		return p, nil
+	}
+	body, err := io.ReadAll(p.reader.reader)
+	if closeErr := p.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return p, fmt.Errorf("reading request body: %w", err)
+	}
+	return payload{body: body, contentType: p.contentType}, nil
+}
+
+func (s *streamReader) Read(b []byte) (int, error) {
+	n, err := s.reader.Read(b)
+	if n > 0 {
+		atomic.StoreInt32(&s.consumed, 1)
+	}
+	return n, err
+}
+
+// length returns the size of the body, -1 when unknown.
+func (p payload) length() int64 {
+	if p.streamed() {
+		return p.size
+	}
+	return int64(len(p.body))
+}
+
+// transformBody applies the body transform, when set, to a buffered body.
+func (r *RestClient) transformBody(ctx context.Context, body payload) (payload, error) {
+	if r.bodyTransform == nil || body.streamed() {
+		return body, nil
+	}
+	transformed, err := r.bodyTransform(ctx, body.body)
+	if err != nil {
+		return body, fmt.Errorf("transforming request body: %w", err)
+	}
+	body.body = transformed
+	return body, nil
+}
+
+// bodyReader returns a reader over the request body, reporting the upload progress when set.
+func (r *RestClient) bodyReader(body payload) io.Reader {
+	var reader io.Reader = bytes.NewReader(body.body)
+	if body.streamed() {
+		reader = body.reader
+	} else if len(body.body) == 0 {
+		return http.NoBody
+	}
+	if r.uploadProgress != nil {
+		reader = &progressReader{reader: reader, total: body.length(), progress: r.uploadProgress}
+	}
+	return reader
+}
+
+// readerSize returns the number of bytes left in the reader, -1 when unknown.
+func readerSize(reader io.Reader) int64 {
+	switch v := reader.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	default:
+		return -1
+	}
+}
+
+// progressReader reports the bytes read from the underlying reader.
+type progressReader struct {
+	reader   io.Reader
+	read     int64
+	total    int64
+	progress func(read, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, p.total)
+	}
+	return n, err
+}
+
+// progressReadCloser reports the bytes read from a response body.
+type progressReadCloser struct {
+	progressReader
+	io.Closer
+}
+
+// trackDownload wraps the response body to report the download progress when set. The bytes
+// are counted as received, before decompression, so they add up to the Content-Length.
+func (r *RestClient) trackDownload(resp *http.Response) {
+	if r.downloadProgress == nil {
+		return
+	}
+	resp.Body = &progressReadCloser{
+		progressReader: progressReader{reader: resp.Body, total: resp.ContentLength, progress: r.downloadProgress},
+		Closer:         resp.Body,
+	}
+}
+
+// maxDrainSize is the size up to which what is left of a response body is discarded before it is
+// closed, so that its connection returns to the pool. Larger leftovers are cheaper to drop along
+// with the connection.
+const maxDrainSize = 256 << 10
+
+// drainAndClose discards what is left of the response body, up to maxDrainSize, and closes it.
+func drainAndClose(body io.ReadCloser) error {
+	io.CopyN(io.Discard, body, maxDrainSize)
+	return body.Close()
+}
+
+// ErrResponseTooLarge is returned when the (decompressed) response body exceeds the size set with WithMaxResponseSize.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// ErrTruncated is returned by DoInto and DoJSONStream when the response body exceeds the size set
+// with WithMaxResponseSize, after delivering the body up to the limit. It wraps ErrResponseTooLarge.
+var ErrTruncated = fmt.Errorf("%w: truncated", ErrResponseTooLarge)
+
+// truncatingReader reads up to a limit, recording whether the underlying reader had more to read.
+type truncatingReader struct {
+	reader    io.Reader
+	remaining int64
+	truncated bool
+}
+
+func (t *truncatingReader) Read(b []byte) (int, error) {
+	if t.remaining <= 0 {
+		// the body is larger than the limit if anything is left to read
+		var next [1]byte
+		if n, _ := io.ReadFull(t.reader, next[:]); n > 0 {
+			t.truncated = true
+		}
+		return 0, io.EOF
+	}
+	if int64(len(b)) > t.remaining {
+		b = b[:t.remaining]
+	}
+	n, err := t.reader.Read(b)
+	t.remaining -= int64(n)
+	return n, err
+}
+
+// truncate limits the reader to the maximum response size, when set. The returned function
+// reports ErrTruncated once the reader has been read past the limit.
+func (r *RestClient) truncate(reader io.Reader) (io.Reader, func() error) {
+	if r.maxResponseSize <= 0 {
+		return reader, func() error { return nil }
+	}
+	limited := &truncatingReader{reader: reader, remaining: r.maxResponseSize}
+	return limited, func() error {
+		if limited.truncated {
+			return fmt.Errorf("%w: exceeds %d bytes", ErrTruncated, r.maxResponseSize)
+		}
+		return nil
+	}
+}
+
+// readBody reads the response body, decompressing it according to its Content-Encoding and
+// enforcing the maximum response size on the decompressed bytes, so a small compressed
+// payload cannot expand beyond it. When reading fails, the bytes read so far are returned with the error.
+func (r *RestClient) readBody(ctx context.Context, resp *http.Response) ([]byte, error) {
+
+	decompressed, err := r.decompress(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer decompressed.Close()
+
+	reader, logBody := r.observeBody(ctx, resp, decompressed)
+	defer logBody()
+
+	if r.maxResponseSize <= 0 {
+		return io.ReadAll(reader)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, r.maxResponseSize+1))
+	if err != nil {
+		return body, err
+	}
+	if int64(len(body)) > r.maxResponseSize {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrResponseTooLarge, r.maxResponseSize)
+	}
+	return body, nil
+}
+
+// ErrCorruptBody is returned when a compressed response body cannot be decompressed
+// because it is corrupt or truncated.
+var ErrCorruptBody = errors.New("corrupt or truncated compressed body")
+
+// decompress wraps the response body with a reader that decompresses it, using the decompressors
+// registered with WithDecompressor before the built-in gzip and deflate ones. Bodies with an unknown
+// encoding are returned as they are. Decompression failures, including the ones of bodies already
+// decompressed by the transport, are reported as ErrCorruptBody.
+func (r *RestClient) decompress(resp *http.Response) (io.ReadCloser, error) {
+	// the response body itself is drained and closed by the caller, so that its connection is reused
+	body := io.NopCloser(resp.Body)
+	if resp.Uncompressed {
+		return &corruptionReader{ReadCloser: body, encoding: "gzip"}, nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	reader, err := r.decompressor(encoding, body)
+	if err != nil {
+		return nil, corruption(encoding, err)
+	}
+	if reader == body {
+		return reader, nil
+	}
+	return &corruptionReader{ReadCloser: reader, encoding: encoding}, nil
+}
+
+// decompressor returns a reader decompressing the body with the given encoding, or the body itself
+// when the encoding is unknown.
+func (r *RestClient) decompressor(encoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	if factory, ok := r.decompressors[encoding]; ok {
+		reader, err := factory(body)
+		if err != nil {
+			return nil, err
+		}
+		if closer, ok := reader.(io.ReadCloser); ok {
+			return closer, nil
+		}
+		return io.NopCloser(reader), nil
+	}
+	switch encoding {
+	case "gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return zlib.NewReader(body)
+	default:
+		return body, nil
+	}
+}
+
+// corruptionReader reports the errors of a decompressing reader caused by a corrupt or truncated body.
+type corruptionReader struct {
+	io.ReadCloser
+	encoding string
+}
+
+func (c *corruptionReader) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	if err != nil && err != io.EOF {
+		err = corruption(c.encoding, err)
+	}
+	return n, err
+}
+
+// corruption wraps the decompression error with ErrCorruptBody when it is caused by the body itself.
+// An empty body is left as it is, as are errors reading from the connection.
+func corruption(encoding string, err error) error {
+	var corrupt flate.CorruptInputError
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, gzip.ErrChecksum), errors.Is(err, gzip.ErrHeader),
+		errors.Is(err, zlib.ErrChecksum), errors.Is(err, zlib.ErrHeader), errors.Is(err, zlib.ErrDictionary),
+		errors.As(err, &corrupt):
+		return fmt.Errorf("%w: %s: %w", ErrCorruptBody, encoding, err)
+	default:
+		return err
+	}
+}
+
+// WithDecompressor registers the factory of the reader decompressing the response bodies with the
+// given Content-Encoding, e.g. "br" or "zstd", replacing the built-in one for gzip and deflate.
+func (r *RestClient) WithDecompressor(encoding string, factory func(io.Reader) (io.Reader, error)) *RestClient {
+	if r.decompressors == nil {
+		r.decompressors = map[string]func(io.Reader) (io.Reader, error){}
+	}
+	r.decompressors[strings.ToLower(strings.TrimSpace(encoding))] = factory
+	return r
+}
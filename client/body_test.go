@@ -0,0 +1,738 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoMaxResponseSize(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		headers         map[string]string
+		payload         string
+		maxResponseSize int64
+		expected        interface{}
+		expectedStatus  int64
+		expectedCalls   int
+		expectedError   error
+	}{
+		{
+			name:            "gzip bomb decompressed by the client",
+			headers:         map[string]string{"Accept-Encoding": "gzip"},
+			payload:         `{"data": "` + strings.Repeat("0", 10<<20) + `"}`,
+			maxResponseSize: 1 << 20,
+			expectedStatus:  internalStatusRequestError,
+			expectedCalls:   1,
+			expectedError:   ErrResponseTooLarge,
+		},
+		{
+			name:            "gzip bomb decompressed by the transport",
+			payload:         `{"data": "` + strings.Repeat("0", 10<<20) + `"}`,
+			maxResponseSize: 1 << 20,
+			expectedStatus:  internalStatusRequestError,
+			expectedCalls:   1,
+			expectedError:   ErrResponseTooLarge,
+		},
+		{
+			name:            "compressed payload within the limit",
+			headers:         map[string]string{"Accept-Encoding": "gzip"},
+			payload:         `{"message": "success"}`,
+			maxResponseSize: 1 << 20,
+			expected:        map[string]interface{}{"message": "success"},
+			expectedStatus:  200,
+			expectedCalls:   1,
+		},
+		{
+			name:           "no limit",
+			headers:        map[string]string{"Accept-Encoding": "gzip"},
+			payload:        `{"message": "success"}`,
+			expected:       map[string]interface{}{"message": "success"},
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var compressed bytes.Buffer
+			writer := gzip.NewWriter(&compressed)
+			writer.Write([]byte(tc.payload))
+			writer.Close()
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write(compressed.Bytes())
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithHeader(tc.headers).
+				WithMaxAttempts(3).
+				WithMaxResponseSize(tc.maxResponseSize)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			if err != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoCapturePartialBody(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		capturePartial  bool
+		expectedPartial []byte
+	}{
+		{
+			name:            "partial body captured",
+			capturePartial:  true,
+			expectedPartial: []byte(`{"items": [1, 2, 3`),
+		},
+		{
+			name:           "partial body not captured",
+			capturePartial: false,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"items": [1, 2, 3`)
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithRequestTimeout(time.Millisecond * 50)
+			if tc.capturePartial {
+				m.WithCapturePartialBody()
+			}
+
+			var result map[string]interface{}
+			res, err := m.DoResult(context.Background(), nil, &result)
+			assertion.ErrorIs(err, context.DeadlineExceeded)
+			assertion.Equal(int64(internalStatusRequestError), res.Status)
+			assertion.Equal(tc.expectedPartial, res.PartialBody)
+		})
+	}
+}
+
+// unsizedReader hides the size of the underlying reader.
+type unsizedReader struct {
+	reader io.Reader
+}
+
+func (u *unsizedReader) Read(b []byte) (int, error) {
+	return u.reader.Read(b)
+}
+
+func TestDoUploadProgress(t *testing.T) {
+
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+
+	tests := []struct {
+		name          string
+		request       interface{}
+		expectedTotal int64
+		expectedSent  int64
+	}{
+		{
+			name:          "streamed body of known size",
+			request:       bytes.NewReader(data),
+			expectedTotal: int64(len(data)),
+			expectedSent:  int64(len(data)),
+		},
+		{
+			name:          "streamed body of unknown size",
+			request:       &unsizedReader{reader: bytes.NewReader(data)},
+			expectedTotal: -1,
+			expectedSent:  int64(len(data)),
+		},
+		{
+			name:          "encoded body",
+			request:       map[string]string{"name": "john"},
+			expectedTotal: 15,
+			expectedSent:  15,
+		},
+		{
+			name:          "large encoded body",
+			request:       map[string]string{"name": string(data)},
+			expectedTotal: int64(len(data)) + 11,
+			expectedSent:  int64(len(data)) + 11,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var received int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received = len(body)
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			var sent, totals []int64
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(1).
+				WithUploadProgress(func(s, total int64) {
+					sent = append(sent, s)
+					totals = append(totals, total)
+				})
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), tc.request, &result)
+			assertion.NoError(err)
+			assertion.Equal(int(tc.expectedSent), received)
+
+			assertion.NotEmpty(sent)
+			for i := range sent {
+				assertion.Equal(tc.expectedTotal, totals[i])
+				if i > 0 {
+					assertion.Greater(sent[i], sent[i-1])
+				}
+			}
+			assertion.Equal(tc.expectedSent, sent[len(sent)-1])
+		})
+	}
+}
+
+func TestDoStreamedBodyNotRetried(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("POST").
+		WithMaxAttempts(3)
+
+	var result map[string]interface{}
+	status, err := m.Do(context.Background(), strings.NewReader("payload"), &result)
+	assertion.Error(err)
+	assertion.Equal(int64(503), status)
+	assertion.Equal(1, calls)
+}
+
+// fileReader is a file-like body counting how many times it was closed.
+type fileReader struct {
+	io.Reader
+	closes int
+}
+
+func (f *fileReader) Close() error {
+	f.closes++
+	return nil
+}
+
+// sizedFileReader is a fileReader whose size is known.
+type sizedFileReader struct {
+	*fileReader
+	size int
+}
+
+func (s *sizedFileReader) Len() int {
+	return s.size
+}
+
+func TestDoReadCloserBody(t *testing.T) {
+
+	payload := "payload"
+
+	tests := []struct {
+		name           string
+		sized          bool
+		expectedStatus int64
+		expectedCalls  int
+		expectedError  bool
+	}{
+		{
+			name:           "body of known size buffered and retried",
+			sized:          true,
+			expectedStatus: 200,
+			expectedCalls:  2,
+		},
+		{
+			name:           "body of unknown size sent on a single attempt",
+			expectedStatus: 503,
+			expectedCalls:  1,
+			expectedError:  true,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				body, _ := io.ReadAll(r.Body)
+				assertion.Equal(payload, string(body))
+				if calls == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(3)
+
+			file := &fileReader{Reader: &unsizedReader{reader: strings.NewReader(payload)}}
+			var request io.ReadCloser = file
+			if tc.sized {
+				request = &sizedFileReader{fileReader: file, size: len(payload)}
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), request, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expectedError, err != nil)
+			assertion.Equal(1, file.closes)
+		})
+	}
+}
+
+func TestDoDownloadProgress(t *testing.T) {
+
+	data := []byte(`"` + strings.Repeat("0123456789", 100000) + `"`)
+
+	tests := []struct {
+		name          string
+		chunked       bool
+		expectedTotal int64
+	}{
+		{
+			name:          "known size",
+			expectedTotal: int64(len(data)),
+		},
+		{
+			name:          "unknown size",
+			chunked:       true,
+			expectedTotal: -1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tc.chunked {
+					w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+				}
+				w.Write(data)
+			}))
+			defer svr.Close()
+
+			var received, totals []int64
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1).
+				WithDownloadProgress(func(r, total int64) {
+					received = append(received, r)
+					totals = append(totals, total)
+				})
+
+			var result string
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Len(result, len(data)-2)
+
+			assertion.NotEmpty(received)
+			for i := range received {
+				assertion.Equal(tc.expectedTotal, totals[i])
+				if i > 0 {
+					assertion.Greater(received[i], received[i-1])
+				}
+			}
+			assertion.Equal(int64(len(data)), received[len(received)-1])
+		})
+	}
+}
+
+func TestDoDecompressor(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		encoding string
+		payload  string
+		expected interface{}
+	}{
+		{
+			name:     "registered encoding",
+			encoding: "x-base64",
+			payload:  base64.StdEncoding.EncodeToString([]byte(`{"message": "success"}`)),
+			expected: map[string]interface{}{"message": "success"},
+		},
+		{
+			name:     "registered encoding is case insensitive",
+			encoding: "X-Base64",
+			payload:  base64.StdEncoding.EncodeToString([]byte(`{"message": "success"}`)),
+			expected: map[string]interface{}{"message": "success"},
+		},
+		{
+			name:     "unknown encoding is left as is",
+			encoding: "x-unknown",
+			payload:  `{"message": "success"}`,
+			expected: map[string]interface{}{"message": "success"},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", tc.encoding)
+				fmt.Fprint(w, tc.payload)
+			}))
+			defer svr.Close()
+
+			used := false
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1).
+				WithDecompressor("x-base64", func(reader io.Reader) (io.Reader, error) {
+					used = true
+					return base64.NewDecoder(base64.StdEncoding, reader), nil
+				})
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+			assertion.Equal(tc.encoding != "x-unknown", used)
+		})
+	}
+}
+
+func TestDoCorruptBody(t *testing.T) {
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(`{"data": "` + strings.Repeat("0123456789", 1000) + `"}`))
+	writer.Close()
+	valid := compressed.Bytes()
+
+	corrupt := append([]byte{}, valid...)
+	// flip a byte of the trailing checksum
+	corrupt[len(corrupt)-8] ^= 0xff
+
+	tests := []struct {
+		name          string
+		headers       map[string]string
+		body          []byte
+		expectedError error
+	}{
+		{
+			name:          "truncated body decompressed by the client",
+			headers:       map[string]string{"Accept-Encoding": "gzip"},
+			body:          valid[:len(valid)/2],
+			expectedError: ErrCorruptBody,
+		},
+		{
+			name:          "truncated body decompressed by the transport",
+			body:          valid[:len(valid)/2],
+			expectedError: ErrCorruptBody,
+		},
+		{
+			name:          "invalid checksum",
+			headers:       map[string]string{"Accept-Encoding": "gzip"},
+			body:          corrupt,
+			expectedError: ErrCorruptBody,
+		},
+		{
+			name:    "valid body",
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			body:    valid,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write(tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithHeader(tc.headers).
+				WithMaxAttempts(1)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			if tc.expectedError == nil {
+				assertion.NoError(err)
+				assertion.Len(result["data"], 10000)
+				return
+			}
+			assertion.ErrorIs(err, tc.expectedError)
+			var syntaxErr *json.SyntaxError
+			assertion.False(errors.As(err, &syntaxErr))
+			assertion.Nil(result)
+		})
+	}
+}
+
+func TestMaxResponseSizeModes(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	payload := `[{"id":1},{"id":2},{"id":3}]`
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, payload)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMaxAttempts(1).
+		WithMaxResponseSize(20)
+
+	t.Run("decoding fails without decoding anything", func(t *testing.T) {
+
+		var result []map[string]int
+		_, err := m.Do(context.Background(), nil, &result)
+		assertion.ErrorIs(err, ErrResponseTooLarge)
+		assertion.NotErrorIs(err, ErrTruncated)
+		assertion.Nil(result)
+	})
+
+	t.Run("copying delivers the body up to the limit", func(t *testing.T) {
+
+		var buf bytes.Buffer
+		status, err := m.DoInto(context.Background(), nil, &buf)
+		assertion.ErrorIs(err, ErrTruncated)
+		assertion.Equal(int64(200), status)
+		assertion.Equal(payload[:20], buf.String())
+	})
+
+	t.Run("streaming delivers the elements within the limit", func(t *testing.T) {
+
+		var elements []string
+		status, err := m.DoJSONStream(context.Background(), nil, func(element json.RawMessage) error {
+			elements = append(elements, string(element))
+			return nil
+		})
+		assertion.ErrorIs(err, ErrTruncated)
+		assertion.Equal(int64(200), status)
+		assertion.Equal([]string{`{"id":1}`, `{"id":2}`}, elements)
+	})
+
+	t.Run("streaming within the limit", func(t *testing.T) {
+
+		var elements []string
+		_, err := m.WithMaxResponseSize(int64(len(payload))).DoJSONStream(context.Background(), nil, func(element json.RawMessage) error {
+			elements = append(elements, string(element))
+			return nil
+		})
+		assertion.NoError(err)
+		assertion.Len(elements, 3)
+	})
+}
+
+func TestDoStreamedBodyExpectContinue(t *testing.T) {
+
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+
+	tests := []struct {
+		name           string
+		expectContinue bool
+		expectedCalls  int
+		expectedStatus int64
+		expectedBodies []int
+	}{
+		{
+			name:           "rejected on the continue handshake and retried",
+			expectContinue: true,
+			expectedCalls:  2,
+			expectedStatus: 200,
+			expectedBodies: []int{0, len(data)},
+		},
+		{
+			name:           "not retried without the continue handshake",
+			expectedCalls:  1,
+			expectedStatus: 503,
+			expectedBodies: []int{0},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var expects []string
+			var bodies []int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				expects = append(expects, r.Header.Get("Expect"))
+				if len(bodies) == 0 {
+					// rejected without reading the body, so no 100 Continue is sent
+					bodies = append(bodies, 0)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, len(body))
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("PUT").
+				WithMaxAttempts(3)
+			if tc.expectContinue {
+				m.WithExpectContinue()
+			}
+
+			var result map[string]interface{}
+			status, _ := m.Do(context.Background(), bytes.NewReader(data), &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Len(expects, tc.expectedCalls)
+			assertion.Equal(tc.expectedBodies, bodies)
+			for _, expect := range expects {
+				if tc.expectContinue {
+					assertion.Equal("100-continue", expect)
+				} else {
+					assertion.Empty(expect)
+				}
+			}
+		})
+	}
+}
+
+func TestDoDrainsBodyOnError(t *testing.T) {
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context, m *RestClient) error
+	}{
+		{
+			name: "response too large",
+			call: func(ctx context.Context, m *RestClient) error {
+				var result []interface{}
+				_, err := m.WithMaxResponseSize(16).Do(ctx, nil, &result)
+				return err
+			},
+		},
+		{
+			name: "stream handler failure",
+			call: func(ctx context.Context, m *RestClient) error {
+				_, err := m.DoJSONStream(ctx, nil, func(json.RawMessage) error {
+					return errors.New("handler failed")
+				})
+				return err
+			},
+		},
+		{
+			name: "copy failure",
+			call: func(ctx context.Context, m *RestClient) error {
+				_, err := m.DoInto(ctx, nil, failingWriter{})
+				return err
+			},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	// larger than what the transport drains by itself on close, 256KB on recent Go versions
+	body := "[" + strings.Repeat(`"element",`, 40<<10) + `"element"]`
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+
+			var reused []bool
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					reused = append(reused, info.Reused)
+				},
+			})
+			for i := 0; i < 3; i++ {
+				assertion.Error(tc.call(ctx, m))
+			}
+			// the connection returns to the pool once the body left unread is drained
+			assertion.Equal([]bool{false, true, true}, reused)
+		})
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker set with WithCircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through, counting the consecutive failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen until the cooldown is over.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through, closing the circuit when it
+	// succeeds and opening it again when it fails.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// ErrCircuitOpen is returned, without retrying, when the circuit breaker does not let a request through.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// WithCircuitBreaker opens the circuit after failureThreshold consecutive requests failed with an
+// error or a 5xx status, failing the following calls with ErrCircuitOpen without sending them.
+// Once the cooldown is over, a single probe request is let through to decide whether to close
+// the circuit again. Zero or a negative threshold disables it.
+func (r *RestClient) WithCircuitBreaker(failureThreshold int, cooldown time.Duration) *RestClient {
+	if failureThreshold < 1 {
+		r.breaker = nil
+		return r
+	}
+	r.breaker = &breaker{
+		threshold: failureThreshold,
+		cooldown:  cooldown,
+		now:       r.now,
+		notify: func(from, to CircuitState) {
+			if r.breakerChange != nil {
+				r.breakerChange(from, to)
+			}
+		},
+	}
+	return r
+}
+
+// WithCircuitBreakerStateChange sets a hook that runs on every transition of the circuit breaker,
+// e.g. to alert when it opens.
+func (r *RestClient) WithCircuitBreakerStateChange(onChange func(from, to CircuitState)) *RestClient {
+	r.breakerChange = onChange
+	return r
+}
+
+// CircuitState returns the state of the circuit breaker, closed when there is none.
+func (r *RestClient) CircuitState() CircuitState {
+	if r.breaker == nil {
+		return CircuitClosed
+	}
+	r.breaker.mu.Lock()
+	defer r.breaker.mu.Unlock()
+	return r.breaker.state
+}
+
+// breaker tracks the outcome of the requests to open the circuit on consecutive failures.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	notify    func(from, to CircuitState)
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// allow reports whether a request can be sent, letting a single probe through once the cooldown is over.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown:
+		b.state = CircuitHalfOpen
+		b.probing = true
+	case b.state == CircuitHalfOpen && !b.probing:
+		b.probing = true
+	case b.state != CircuitClosed:
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.transition(from, to)
+	return nil
+}
+
+// report records the outcome of a request let through, opening or closing the circuit accordingly.
+func (b *breaker) report(failed bool) {
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case CircuitClosed:
+		if failed {
+			b.failures++
+		} else {
+			b.failures = 0
+		}
+	case CircuitHalfOpen:
+		b.probing = false
+		if !failed {
+			b.state = CircuitClosed
+			b.failures = 0
+		}
+	}
+	if failed && (b.state == CircuitHalfOpen || b.failures >= b.threshold) {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.transition(from, to)
+}
+
+// cancel releases the probe of a half-open circuit whose request was canceled by the caller,
+// without counting it as a success or a failure.
+func (b *breaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+	}
+}
+
+// transition notifies the state change, if any, outside of the lock so that the hook can
+// inspect the breaker.
+func (b *breaker) transition(from, to CircuitState) {
+	if from != to {
+		b.notify(from, to)
+	}
+}
+
+// send sends the request with the client, through the circuit breaker when set.
+func (r *RestClient) send(client *http.Client, req *http.Request) (*http.Response, error) {
+	if r.breaker == nil {
+		return r.hedge(client, req)
+	}
+	if err := r.breaker.allow(); err != nil {
+		// like the transport would, so that nothing waits on the body of a request never sent
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := r.hedge(client, req)
+	if err != nil && req.Context().Err() != nil {
+		r.breaker.cancel()
+		return resp, err
+	}
+	r.breaker.report(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	return resp, err
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mauriciozanettisalomao/go-rest-client/client/clienttest"
+	"github.com/stretchr/testify/assert"
+)
+
+type transition struct {
+	from, to CircuitState
+}
+
+func TestDoCircuitBreakerStateChange(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var calls int
+	status := http.StatusInternalServerError
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"message": "done"}`)
+	}))
+	defer svr.Close()
+
+	clock := clienttest.NewFakeClock(time.Now())
+	var transitions []transition
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithClock(clock).
+		WithCircuitBreakerStateChange(func(from, to CircuitState) {
+			transitions = append(transitions, transition{from: from, to: to})
+		}).
+		WithCircuitBreaker(2, time.Minute)
+
+	steps := []struct {
+		advance       time.Duration
+		status        int
+		expectedState CircuitState
+		expectedCalls int
+		expectedOpen  bool
+	}{
+		// the first failure is tolerated, the second one opens the circuit
+		{status: http.StatusInternalServerError, expectedState: CircuitClosed, expectedCalls: 1},
+		{status: http.StatusInternalServerError, expectedState: CircuitOpen, expectedCalls: 2},
+		// the open circuit rejects the call without sending it
+		{status: http.StatusOK, expectedState: CircuitOpen, expectedCalls: 2, expectedOpen: true},
+		// once the cooldown is over, a failed probe opens the circuit again
+		{advance: time.Minute, status: http.StatusInternalServerError, expectedState: CircuitOpen, expectedCalls: 3},
+		{advance: time.Second, status: http.StatusOK, expectedState: CircuitOpen, expectedCalls: 3, expectedOpen: true},
+		// and a successful one closes it
+		{advance: time.Minute, status: http.StatusOK, expectedState: CircuitClosed, expectedCalls: 4},
+	}
+
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		status = step.status
+
+		var result map[string]interface{}
+		_, err := m.Do(context.Background(), nil, &result)
+		if step.expectedOpen {
+			assertion.ErrorIs(err, ErrCircuitOpen, "step %d", i)
+		} else {
+			assertion.NotErrorIs(err, ErrCircuitOpen, "step %d", i)
+		}
+		assertion.Equal(step.expectedState, m.CircuitState(), "step %d", i)
+		assertion.Equal(step.expectedCalls, calls, "step %d", i)
+	}
+
+	assertion.Equal([]transition{
+		{from: CircuitClosed, to: CircuitOpen},
+		{from: CircuitOpen, to: CircuitHalfOpen},
+		{from: CircuitHalfOpen, to: CircuitOpen},
+		{from: CircuitOpen, to: CircuitHalfOpen},
+		{from: CircuitHalfOpen, to: CircuitClosed},
+	}, transitions)
+}
+
+func TestDoCircuitBreakerNotRetried(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var calls int
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(5).
+		WithCircuitBreaker(2, time.Minute)
+
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	assertion.ErrorIs(err, ErrCircuitOpen)
+	var exhausted *RetryExhaustedError
+	assertion.False(errors.As(err, &exhausted))
+	// the retries stop as soon as the circuit opens
+	assertion.Equal(2, calls)
+	assertion.Equal(CircuitOpen, m.CircuitState())
+}
+
+func TestCircuitStateString(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	assertion.Equal("closed", CircuitClosed.String())
+	assertion.Equal("open", CircuitOpen.String())
+	assertion.Equal("half-open", CircuitHalfOpen.String())
+	assertion.Equal("CircuitState(7)", CircuitState(7).String())
+}
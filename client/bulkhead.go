@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// bulkhead caps the requests in flight to each host, so a slow host cannot hold all the connections.
+type bulkhead struct {
+	mu    sync.Mutex
+	limit int
+	hosts map[string]chan struct{}
+}
+
+func newBulkhead(limit int) *bulkhead {
+	return &bulkhead{
+		limit: limit,
+		hosts: map[string]chan struct{}{},
+	}
+}
+
+// acquire waits for a free slot for the host, returning the function releasing it,
+// or the context error if the context is done first.
+func (b *bulkhead) acquire(ctx context.Context, host string) (func(), error) {
+	b.mu.Lock()
+	slots, ok := b.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, b.limit)
+		b.hosts[host] = slots
+	}
+	b.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-slots }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WithMaxConcurrentRequests caps the requests in flight to each host at n, shared by all the calls
+// made with the client. Attempts beyond the cap wait for a request to finish, or for the context
+// to be done. Zero or a negative n removes the cap.
+func (r *RestClient) WithMaxConcurrentRequests(n int) *RestClient {
+	r.bulkhead = nil
+	if n > 0 {
+		r.bulkhead = newBulkhead(n)
+	}
+	return r
+}
+
+// acquireSlot waits for a slot for the host of the request when the requests in flight are capped.
+func (r *RestClient) acquireSlot(ctx context.Context, host string) (func(), error) {
+	if r.bulkhead == nil {
+		return func() {}, nil
+	}
+	return r.bulkhead.acquire(ctx, host)
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoMaxConcurrentRequests(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		limit       int
+		calls       int
+		expectedMax int32
+	}{
+		{
+			name:        "capped",
+			limit:       3,
+			calls:       12,
+			expectedMax: 3,
+		},
+		{
+			name:        "single request at a time",
+			limit:       1,
+			calls:       5,
+			expectedMax: 1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var inFlight, maxInFlight int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				current := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					observed := atomic.LoadInt32(&maxInFlight)
+					if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1).
+				WithMaxConcurrentRequests(tc.limit)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, tc.calls)
+			for i := 0; i < tc.calls; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var result map[string]interface{}
+					_, err := m.Do(context.Background(), nil, &result)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				assertion.NoError(err)
+			}
+			assertion.Equal(tc.expectedMax, atomic.LoadInt32(&maxInFlight))
+		})
+	}
+}
+
+func TestDoMaxConcurrentRequestsContextDone(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	unblock := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+	defer close(unblock)
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMaxAttempts(1).
+		WithMaxConcurrentRequests(1)
+
+	go func() {
+		var result map[string]interface{}
+		m.Do(context.Background(), nil, &result)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var result map[string]interface{}
+	_, err := m.Do(ctx, nil, &result)
+	assertion.ErrorIs(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultCacheEntries is the number of responses kept by the response cache, past which the least
+// recently used ones are evicted.
+const defaultCacheEntries = 1000
+
+// cacheKeyHeaders are the request headers telling apart the responses cached for the same URL,
+// e.g. the ones of callers with different credentials.
+var cacheKeyHeaders = []string{"Authorization", "Cookie", "Accept", "Accept-Language"}
+
+// cacheEntry is a response kept for conditional requests.
+type cacheEntry struct {
+	status int64
+	header http.Header
+	body   []byte
+}
+
+// cacheItem is an entry of the response cache along with its key.
+type cacheItem struct {
+	key   string
+	entry cacheEntry
+}
+
+// responseCache keeps the last response received for each URL and set of cacheKeyHeaders that
+// carried a validator (ETag or Last-Modified), so it can be revalidated and served again on a 304.
+// It keeps up to maxEntries responses, evicting the least recently used ones.
+type responseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // of *cacheItem, the most recently used first
+}
+
+func (c *responseCache) get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheItem).entry, true
+}
+
+func (c *responseCache) put(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheItem).entry = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheItem{key: key, entry: entry})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheItem).key)
+	}
+}
+
+// cacheKey returns the key of the response to the request to the URL with the given headers.
+func cacheKey(url string, header http.Header) string {
+	var key strings.Builder
+	key.WriteString(url)
+	for _, name := range cacheKeyHeaders {
+		key.WriteString("\n")
+		key.WriteString(strings.Join(header.Values(name), ", "))
+	}
+	return key.String()
+}
+
+// conditional sets the conditional headers of the request from the cached entry.
+func (e cacheEntry) conditional(req *http.Request) {
+	if etag := e.header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := e.header.Get("Last-Modified"); lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// cacheable reports whether the response carries a validator that allows revalidating it, and
+// does not vary on anything but the request.
+func cacheable(header http.Header) bool {
+	if strings.TrimSpace(header.Get("Vary")) == "*" {
+		return false
+	}
+	return header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+}
+
+func newResponseCache(maxEntries int) *responseCache {
+	return &responseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
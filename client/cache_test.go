@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoCache(t *testing.T) {
+
+	tests := []struct {
+		name                string
+		header              string
+		value               string
+		conditionalHeader   string
+		expectedFromCache   []bool
+		expectedNotModified []bool
+	}{
+		{
+			name:                "etag",
+			header:              "ETag",
+			value:               `"v1"`,
+			conditionalHeader:   "If-None-Match",
+			expectedFromCache:   []bool{false, true, true},
+			expectedNotModified: []bool{false, true, true},
+		},
+		{
+			name:                "last modified",
+			header:              "Last-Modified",
+			value:               "Wed, 21 Oct 2015 07:28:00 GMT",
+			conditionalHeader:   "If-Modified-Since",
+			expectedFromCache:   []bool{false, true, true},
+			expectedNotModified: []bool{false, true, true},
+		},
+		{
+			name:                "no validator",
+			expectedFromCache:   []bool{false, false, false},
+			expectedNotModified: []bool{false, false, false},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.header != "" {
+					w.Header().Set(tc.header, tc.value)
+				}
+				if tc.conditionalHeader != "" && r.Header.Get(tc.conditionalHeader) == tc.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithCache()
+
+			for i := range tc.expectedFromCache {
+				var result map[string]interface{}
+				res, err := m.DoResult(context.Background(), nil, &result)
+				assertion.NoError(err)
+				assertion.Equal(int64(200), res.Status)
+				assertion.Equal(map[string]interface{}{"message": "success"}, result)
+				assertion.Equal(tc.expectedFromCache[i], res.FromCache)
+				assertion.Equal(tc.expectedNotModified[i], res.NotModified)
+			}
+		})
+	}
+}
+
+func TestDoCacheAuthorization(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	// each caller gets its own version of the resource, revalidated with the ETag it was sent
+	var notModified bool
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Header.Get("Authorization")
+		etag := `"` + user + `"`
+		w.Header().Set("ETag", etag)
+		notModified = r.Header.Get("If-None-Match") == etag
+		if notModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, `{"user": %q}`, user)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithCache()
+
+	steps := []struct {
+		user                string
+		expectedNotModified bool
+	}{
+		{user: "alice", expectedNotModified: false},
+		{user: "bob", expectedNotModified: false},
+		{user: "alice", expectedNotModified: true},
+		{user: "bob", expectedNotModified: true},
+	}
+
+	for i, step := range steps {
+		var result map[string]interface{}
+		status, err := m.DoWithHeaders(context.Background(), map[string]string{"Authorization": step.user}, nil, &result)
+		assertion.NoError(err, "step %d", i)
+		assertion.Equal(int64(200), status, "step %d", i)
+		assertion.Equal(map[string]interface{}{"user": step.user}, result, "step %d", i)
+		assertion.Equal(step.expectedNotModified, notModified, "step %d", i)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	cache := newResponseCache(2)
+	cache.put("a", cacheEntry{body: []byte("a")})
+	cache.put("b", cacheEntry{body: []byte("b")})
+
+	// reading a makes b the least recently used entry, evicted by c
+	_, ok := cache.get("a")
+	assertion.True(ok)
+	cache.put("c", cacheEntry{body: []byte("c")})
+
+	_, ok = cache.get("b")
+	assertion.False(ok)
+	for _, key := range []string{"a", "c"} {
+		entry, ok := cache.get(key)
+		assertion.True(ok, key)
+		assertion.Equal([]byte(key), entry.body)
+	}
+}
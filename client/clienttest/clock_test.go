@@ -0,0 +1,132 @@
+package clienttest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mauriciozanettisalomao/go-rest-client/client"
+	"github.com/mauriciozanettisalomao/go-rest-client/client/clienttest"
+)
+
+var _ client.Clock = (*clienttest.FakeClock)(nil)
+
+func TestFakeClockBackoff(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		maxAttempts     int64
+		intervalSeconds float64
+		backoffRate     float64
+		initialJitter   time.Duration
+		expectedSleeps  []time.Duration
+	}{
+		{
+			name:            "exponential backoff",
+			maxAttempts:     4,
+			intervalSeconds: 1,
+			backoffRate:     2,
+			expectedSleeps:  []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:            "constant interval",
+			maxAttempts:     3,
+			intervalSeconds: 0.5,
+			backoffRate:     1,
+			expectedSleeps:  []time.Duration{500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name:           "no backoff",
+			maxAttempts:    3,
+			expectedSleeps: nil,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer svr.Close()
+
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			clock := clienttest.NewFakeClock(start)
+			m := client.NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(tc.maxAttempts).
+				WithIntervalSeconds(tc.intervalSeconds).
+				WithBackoffRate(tc.backoffRate).
+				WithClock(clock)
+
+			began := time.Now()
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.Error(err)
+			assertion.Less(time.Since(began), time.Second)
+
+			assertion.Equal(int(tc.maxAttempts), calls)
+			assertion.Equal(tc.expectedSleeps, clock.Sleeps())
+
+			var total time.Duration
+			for _, sleep := range tc.expectedSleeps {
+				total += sleep
+			}
+			assertion.Equal(start.Add(total), clock.Now())
+		})
+	}
+}
+
+func TestFakeClockInitialJitter(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer svr.Close()
+
+	sleeps := func() []time.Duration {
+		clock := clienttest.NewFakeClock(time.Now())
+		m := client.NewRestClient().
+			WithURL(svr.URL).
+			WithMaxAttempts(1).
+			WithInitialJitter(time.Minute).
+			WithSeed(42).
+			WithClock(clock)
+		var result map[string]interface{}
+		_, err := m.Do(context.Background(), nil, &result)
+		assertion.NoError(err)
+		return clock.Sleeps()
+	}
+
+	first := sleeps()
+	if assertion.Len(first, 1) {
+		assertion.LessOrEqual(first[0], time.Minute)
+	}
+	// the same seed requests the same jitter
+	assertion.Equal(first, sleeps())
+}
+
+func TestFakeClockContextDone(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	clock := clienttest.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assertion.ErrorIs(clock.Sleep(ctx, time.Second), context.Canceled)
+	assertion.Empty(clock.Sleeps())
+
+	clock.Advance(time.Hour)
+	assertion.Empty(clock.Sleeps())
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides the time to the client, allowing tests to control the waits between attempts
+// and the time left to the deadlines of the attempts.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep waits for the given duration, returning early with the context error if it is done first.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the Clock backed by the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	return wait(ctx, d)
+}
+
+// WithClock sets the clock used by the client, the system clock by default.
+func (r *RestClient) WithClock(clock Clock) *RestClient {
+	r.clock = clock
+	return r
+}
+
+// now returns the current time according to the clock of the client.
+func (r *RestClient) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+// sleep waits for the given duration according to the clock of the client.
+func (r *RestClient) sleep(ctx context.Context, d time.Duration) error {
+	if r.clock == nil || d <= 0 {
+		return wait(ctx, d)
+	}
+	return r.clock.Sleep(ctx, d)
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingClock is a Clock that records the requested sleeps instead of waiting.
+type recordingClock struct {
+	sleeps []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *recordingClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	return ctx.Err()
+}
+
+func TestDoInitialJitter(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		initialJitter  time.Duration
+		seed           int64
+		expectedSleeps []time.Duration
+	}{
+		{
+			name:           "jitter with a fixed seed",
+			initialJitter:  time.Second,
+			seed:           42,
+			expectedSleeps: []time.Duration{time.Duration(rand.New(rand.NewSource(42)).Int63n(int64(time.Second) + 1))},
+		},
+		{
+			name:           "another seed",
+			initialJitter:  time.Millisecond * 500,
+			seed:           7,
+			expectedSleeps: []time.Duration{time.Duration(rand.New(rand.NewSource(7)).Int63n(int64(time.Millisecond*500) + 1))},
+		},
+		{
+			name: "no jitter by default",
+			seed: 42,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			clock := &recordingClock{}
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithClock(clock).
+				WithSeed(tc.seed).
+				WithInitialJitter(tc.initialJitter)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedSleeps, clock.sleeps)
+			for _, sleep := range clock.sleeps {
+				assertion.GreaterOrEqual(sleep, time.Duration(0))
+				assertion.LessOrEqual(sleep, tc.initialJitter)
+			}
+		})
+	}
+}
+
+func TestDoInitialJitterContextAware(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithInitialJitter(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	var result map[string]interface{}
+	_, err := m.Do(ctx, nil, &result)
+	assertion.ErrorIs(err, context.DeadlineExceeded)
+	assertion.Equal(0, calls)
+}
@@ -0,0 +1,138 @@
+package client
+
+import "io"
+
+// Clone returns a copy of the client to be tweaked, e.g. with other headers, without affecting
+// the original. The headers, status lists, URLs and other settings are copied, while the
+// transport is shared, so that the clone reuses the pooled connections of the original rather
+// than opening its own; so are the response cache, the bulkhead, the circuit breaker, the load
+// balancer and the latencies observed for the adaptive backoff. Changing a transport setting on
+// the clone gives it a transport of its own, and Close on either client releases the idle
+// connections of both. The clone is not shut down along with the original.
+func (r *RestClient) Clone() *RestClient {
+	c := &RestClient{
+		name:              r.name,
+		method:            r.method,
+		methodOverride:    r.methodOverride,
+		url:               r.url,
+		userAgent:         r.userAgent,
+		urls:              append([]string(nil), r.urls...),
+		retry:             r.retry,
+		timeout:           r.timeout,
+		requestTimeout:    r.requestTimeout,
+		timeoutGrowth:     r.timeoutGrowth,
+		maxTimeout:        r.maxTimeout,
+		timeoutJitter:     r.timeoutJitter,
+		totalTimeout:      r.totalTimeout,
+		requestHook:       r.requestHook,
+		preflight:         r.preflight,
+		latencyObjective:  r.latencyObjective,
+		onLatencyBreach:   r.onLatencyBreach,
+		rawResponse:       r.rawResponse,
+		beforeRetry:       r.beforeRetry,
+		onRetry:           r.onRetry,
+		validator:         r.validator,
+		bodyTransform:     r.bodyTransform,
+		encryptor:         r.encryptor,
+		encryptionHeader:  r.encryptionHeader,
+		encryptionValue:   r.encryptionValue,
+		decryptor:         r.decryptor,
+		expectContinue:    r.expectContinue,
+		responseTransform: r.responseTransform,
+		fallback:          r.fallback,
+		encoder:           r.encoder,
+		uploadProgress:    r.uploadProgress,
+		downloadProgress:  r.downloadProgress,
+		observeLimit:      r.observeLimit,
+		observeRedact:     r.observeRedact,
+		responseType:      r.responseType,
+		bulkhead:          r.bulkhead,
+		expectedType:      r.expectedType,
+		requireBody:       r.requireBody,
+		sniffContentType:  r.sniffContentType,
+		schemaCompiler:    r.schemaCompiler,
+		responseSchema:    r.responseSchema,
+		requestSchema:     r.requestSchema,
+		classifier:        r.classifier,
+		successStatuses:   append([]int(nil), r.successStatuses...),
+		retryOnStatuses:   append([]int(nil), r.retryOnStatuses...),
+		noRetryStatuses:   append([]int(nil), r.noRetryStatuses...),
+		requestIDHeader:   r.requestIDHeader,
+		generatedIDHeader: r.generatedIDHeader,
+		idGenerator:       r.idGenerator,
+		deadlineHeader:    r.deadlineHeader,
+		dryRun:            r.dryRun,
+		cache:             r.cache,
+		maxResponseSize:   r.maxResponseSize,
+		capturePartial:    r.capturePartial,
+		proxy:             r.proxy,
+		proxyUsername:     r.proxyUsername,
+		proxyPassword:     r.proxyPassword,
+		headerTimeout:     r.headerTimeout,
+		tlsTimeout:        r.tlsTimeout,
+		disableKeepAlives: r.disableKeepAlives,
+		dialOverride:      r.dialOverride,
+		hedgeDelay:        r.hedgeDelay,
+		maxHedges:         r.maxHedges,
+		failFastDNS:       r.failFastDNS,
+		retryBodyRead:     r.retryBodyRead,
+		netErrors:         append([]error(nil), r.netErrors...),
+		transport:         r.shareTransport(),
+		sharedTransport:   true,
+		baseCtx:           r.baseCtx,
+		balancer:          r.balancer,
+		breaker:           r.breaker,
+		breakerChange:     r.breakerChange,
+		latency:           r.latency,
+		rnd:               r.rnd,
+		clock:             r.clock,
+		initialJitter:     r.initialJitter,
+	}
+
+	if r.logFields != nil {
+		c.logFields = make(map[string]interface{}, len(r.logFields))
+		for key, value := range r.logFields {
+			c.logFields[key] = value
+		}
+	}
+	c.header = copyStrings(r.header)
+	c.rawHeader = copyStrings(r.rawHeader)
+	if r.methodRetry != nil {
+		c.methodRetry = make(map[string]RetryPolicy, len(r.methodRetry))
+		for method, policy := range r.methodRetry {
+			c.methodRetry[method] = policy
+		}
+	}
+	if r.noBodyStatuses != nil {
+		c.noBodyStatuses = append([]int{}, r.noBodyStatuses...)
+	}
+	if r.statusDecoders != nil {
+		c.statusDecoders = make(map[int]statusDecoder, len(r.statusDecoders))
+		for status, decoder := range r.statusDecoders {
+			c.statusDecoders[status] = decoder
+		}
+	}
+	if r.decompressors != nil {
+		c.decompressors = make(map[string]func(io.Reader) (io.Reader, error), len(r.decompressors))
+		for encoding, factory := range r.decompressors {
+			c.decompressors[encoding] = factory
+		}
+	}
+	if r.maxRedirects != nil {
+		maxRedirects := *r.maxRedirects
+		c.maxRedirects = &maxRedirects
+	}
+	return c
+}
+
+// copyStrings returns a copy of the map, nil when it is nil.
+func copyStrings(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
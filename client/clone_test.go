@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneReusesConnections(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var received []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Tenant"))
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	header := map[string]string{"X-Tenant": "acme"}
+	original := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithHeader(header)
+
+	var reused []bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = append(reused, info.Reused)
+		},
+	})
+
+	var result map[string]interface{}
+	_, err := original.Do(ctx, nil, &result)
+	assertion.NoError(err)
+
+	clone := original.Clone()
+	_, err = clone.Do(ctx, nil, &result)
+	assertion.NoError(err)
+
+	// the clone goes through the connection opened by the original
+	assertion.Equal([]bool{false, true}, reused)
+	assertion.Same(original.httpTransport(), clone.httpTransport())
+
+	// the headers of the clone are its own
+	header["X-Tenant"] = "globex"
+	_, err = clone.Do(ctx, nil, &result)
+	assertion.NoError(err)
+	_, err = original.Do(ctx, nil, &result)
+	assertion.NoError(err)
+	assertion.Equal([]string{"acme", "acme", "acme", "globex"}, received)
+	assertion.Equal([]bool{false, true, true, true}, reused)
+}
+
+func TestCloneTransportSetting(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	original := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1)
+
+	var reused []bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = append(reused, info.Reused)
+		},
+	})
+
+	var result map[string]interface{}
+	_, err := original.Do(ctx, nil, &result)
+	assertion.NoError(err)
+
+	// a clone changing a transport setting gets a transport of its own, leaving the pool of the original alone
+	clone := original.Clone().WithDisableKeepAlives()
+	assertion.NotSame(original.httpTransport(), clone.httpTransport())
+
+	_, err = clone.Do(ctx, nil, &result)
+	assertion.NoError(err)
+	_, err = original.Do(ctx, nil, &result)
+	assertion.NoError(err)
+	assertion.Equal([]bool{false, false, true}, reused)
+}
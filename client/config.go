@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Config holds the settings of a client as plain values, for clients built from configuration
+// files or the environment instead of chained calls.
+type Config struct {
+	Method          string
+	URL             string
+	Headers         map[string]string
+	UserAgent       string
+	Timeout         time.Duration
+	MaxAttempts     int64
+	IntervalSeconds float64
+	BackoffRate     float64
+}
+
+// validate returns all the problems found in the config, joined in a single error.
+func (cfg Config) validate() error {
+
+	var errs []error
+
+	u, err := url.Parse(cfg.URL)
+	switch {
+	case cfg.URL == "":
+		errs = append(errs, errors.New("url is required"))
+	case err != nil:
+		errs = append(errs, fmt.Errorf("invalid url: %w", err))
+	case (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		errs = append(errs, fmt.Errorf("url %q must be an absolute http or https url", cfg.URL))
+	}
+
+	if cfg.Method != "" {
+		if _, err := http.NewRequest(cfg.Method, "http://localhost", nil); err != nil {
+			errs = append(errs, fmt.Errorf("invalid method %q", cfg.Method))
+		}
+	}
+
+	for name := range cfg.Headers {
+		if name == "" {
+			errs = append(errs, errors.New("header names must not be empty"))
+		}
+	}
+
+	if cfg.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative, got %s", cfg.Timeout))
+	}
+	if cfg.MaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("max attempts must not be negative, got %d", cfg.MaxAttempts))
+	}
+	// the max attempts, which default to one when left unset, are checked above
+	policy := RetryPolicy{MaxAttempts: 1, IntervalSeconds: cfg.IntervalSeconds, BackoffRate: cfg.BackoffRate}
+	if err := policy.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewRestClientFromConfig creates a new Rest Client from the config, returning an error describing
+// every invalid setting instead. The settings left unset are the ones set with SetDefaults, if any;
+// the method then defaults to GET and the max attempts to one.
+func NewRestClientFromConfig(cfg Config) (*RestClient, error) {
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	r := NewRestClient()
+	r.apply(cfg)
+	if r.method == "" {
+		r.method = http.MethodGet
+	}
+	if r.retry.MaxAttempts == 0 {
+		r.retry.MaxAttempts = 1
+	}
+	return r, nil
+}
+
+var (
+	defaultsMu sync.RWMutex
+	defaults   Config
+)
+
+// SetDefaults sets the settings every client created afterwards with NewRestClient starts from,
+// e.g. an organization-wide user agent, timeout and retry policy, which its own setters then
+// override. Clients created before the call are not affected. It is safe to call concurrently
+// with the creation of clients; SetDefaults(Config{}) clears the defaults.
+func SetDefaults(cfg Config) {
+	cfg.Headers = copyStrings(cfg.Headers)
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+	defaults = cfg
+}
+
+// applyDefaults applies the settings set with SetDefaults to the client.
+func (r *RestClient) applyDefaults() {
+	defaultsMu.RLock()
+	cfg := defaults
+	defaultsMu.RUnlock()
+	r.apply(cfg)
+}
+
+// apply applies the settings set in the config to the client, leaving the others untouched.
+func (r *RestClient) apply(cfg Config) {
+	if cfg.Method != "" {
+		r.method = cfg.Method
+	}
+	if cfg.URL != "" {
+		r.url = cfg.URL
+	}
+	if cfg.Headers != nil {
+		r.header = copyStrings(cfg.Headers)
+	}
+	if cfg.UserAgent != "" {
+		r.userAgent = cfg.UserAgent
+	}
+	if cfg.Timeout != 0 {
+		r.timeout = cfg.Timeout
+	}
+	if cfg.MaxAttempts != 0 {
+		r.retry.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.IntervalSeconds != 0 {
+		r.retry.IntervalSeconds = cfg.IntervalSeconds
+	}
+	if cfg.BackoffRate != 0 {
+		r.retry.BackoffRate = cfg.BackoffRate
+	}
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRestClientFromConfig(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		config          Config
+		failCalls       int
+		expectedMethod  string
+		expectedHeaders map[string]string
+		expectedCalls   int
+		expectedStatus  int64
+	}{
+		{
+			name: "full config",
+			config: Config{
+				Method:          "POST",
+				Headers:         map[string]string{"Authorization": "Bearer token", "X-Request-Source": "config"},
+				Timeout:         time.Second,
+				MaxAttempts:     3,
+				IntervalSeconds: 0.01,
+				BackoffRate:     1,
+			},
+			failCalls:       2,
+			expectedMethod:  "POST",
+			expectedHeaders: map[string]string{"Authorization": "Bearer token", "X-Request-Source": "config"},
+			expectedCalls:   3,
+			expectedStatus:  200,
+		},
+		{
+			name:           "defaults",
+			config:         Config{},
+			failCalls:      1,
+			expectedMethod: "GET",
+			expectedCalls:  1,
+			expectedStatus: 503,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			var method string
+			headers := http.Header{}
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				method = r.Method
+				headers = r.Header
+				if calls <= tc.failCalls {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			tc.config.URL = svr.URL
+			m, err := NewRestClientFromConfig(tc.config)
+			assertion.NoError(err)
+
+			var result map[string]interface{}
+			status, _ := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expectedMethod, method)
+			for name, value := range tc.expectedHeaders {
+				assertion.Equal(value, headers.Get(name))
+			}
+		})
+	}
+}
+
+func TestNewRestClientFromConfigInvalid(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		config        Config
+		expectedError []string
+	}{
+		{
+			name:          "missing url",
+			config:        Config{},
+			expectedError: []string{"url is required"},
+		},
+		{
+			name:          "relative url",
+			config:        Config{URL: "/orders"},
+			expectedError: []string{"absolute http or https url"},
+		},
+		{
+			name:          "invalid method",
+			config:        Config{URL: "http://localhost", Method: "GET ME"},
+			expectedError: []string{`invalid method "GET ME"`},
+		},
+		{
+			name:          "interval without backoff rate",
+			config:        Config{URL: "http://localhost", MaxAttempts: 3, IntervalSeconds: 1},
+			expectedError: []string{"backoff rate is required"},
+		},
+		{
+			name: "several problems",
+			config: Config{
+				URL:         "ftp://localhost",
+				Timeout:     -time.Second,
+				MaxAttempts: -1,
+			},
+			expectedError: []string{"absolute http or https url", "timeout must not be negative", "max attempts must not be negative"},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			m, err := NewRestClientFromConfig(tc.config)
+			assertion.Nil(m)
+			assertion.Error(err)
+			for _, expected := range tc.expectedError {
+				assertion.Contains(err.Error(), expected)
+			}
+		})
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+
+	tests := []struct {
+		name              string
+		client            func() *RestClient
+		expectedCalls     int
+		expectedUserAgent string
+		expectedTenant    string
+	}{
+		{
+			name:              "defaults inherited",
+			client:            NewRestClient,
+			expectedCalls:     3,
+			expectedUserAgent: "acme-service/1.0",
+			expectedTenant:    "acme",
+		},
+		{
+			name: "defaults overridden by the setters",
+			client: func() *RestClient {
+				return NewRestClient().
+					WithMaxAttempts(1).
+					WithUserAgent("reporting-job/2.0").
+					WithHeader(map[string]string{"X-Tenant": "globex"})
+			},
+			expectedCalls:     1,
+			expectedUserAgent: "reporting-job/2.0",
+			expectedTenant:    "globex",
+		},
+		{
+			name: "defaults inherited by clients from a config",
+			client: func() *RestClient {
+				m, err := NewRestClientFromConfig(Config{URL: "http://localhost", MaxAttempts: 2})
+				assert.NoError(t, err)
+				return m
+			},
+			expectedCalls:     2,
+			expectedUserAgent: "acme-service/1.0",
+			expectedTenant:    "acme",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var (
+				calls     int
+				userAgent string
+				tenant    string
+			)
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				userAgent = r.Header.Get("User-Agent")
+				tenant = r.Header.Get("X-Tenant")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer svr.Close()
+
+			headers := map[string]string{"X-Tenant": "acme"}
+			SetDefaults(Config{
+				Method:      "GET",
+				Headers:     headers,
+				UserAgent:   "acme-service/1.0",
+				Timeout:     time.Second,
+				MaxAttempts: 3,
+			})
+			t.Cleanup(func() { SetDefaults(Config{}) })
+			// the defaults are a copy of the config
+			headers["X-Tenant"] = "changed"
+
+			m := tc.client().WithURL(svr.URL)
+			assertion.Equal(time.Second, m.timeout)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.Error(err)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expectedUserAgent, userAgent)
+			assertion.Equal(tc.expectedTenant, tenant)
+		})
+	}
+}
+
+func TestSetDefaultsAffectsNewClientsOnly(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	before := NewRestClient()
+	SetDefaults(Config{UserAgent: "acme-service/1.0", MaxAttempts: 3})
+	t.Cleanup(func() { SetDefaults(Config{}) })
+
+	assertion.Empty(before.userAgent)
+	assertion.Equal(int64(0), before.retry.MaxAttempts)
+	assertion.Equal("acme-service/1.0", NewRestClient().userAgent)
+
+	// safe to use concurrently with the creation of clients
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetDefaults(Config{MaxAttempts: int64(i + 1)})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assertion.Greater(NewRestClient().retry.MaxAttempts, int64(0))
+	}
+	<-done
+}
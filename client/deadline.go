@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	grpcTimeoutHeader = "Grpc-Timeout"
+	grpcTimeoutMax    = 100000000 // values have at most eight digits
+)
+
+// WithDeadlinePropagation sends the time left before the deadline of the request context in the
+// given header, so downstream services can give up on work the client will not wait for. The time
+// is sent in milliseconds, or in the gRPC format (e.g. "1500m") when the header is grpc-timeout.
+// Nothing is sent when the context has no deadline.
+func (r *RestClient) WithDeadlinePropagation(headerName string) *RestClient {
+	r.deadlineHeader = headerName
+	return r
+}
+
+// setDeadline sets the deadline propagation header on the request from the deadline of the context.
+func (r *RestClient) setDeadline(ctx context.Context, req *http.Request) {
+	if r.deadlineHeader == "" {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	remaining := deadline.Sub(r.now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	if strings.EqualFold(r.deadlineHeader, grpcTimeoutHeader) {
+		req.Header.Set(r.deadlineHeader, grpcTimeout(remaining))
+		return
+	}
+	req.Header.Set(r.deadlineHeader, strconv.FormatInt(remaining.Milliseconds(), 10))
+}
+
+// grpcTimeout formats the duration as a gRPC timeout, in the most precise unit fitting in eight digits.
+func grpcTimeout(d time.Duration) string {
+	units := []struct {
+		unit     time.Duration
+		shortcut string
+	}{
+		{time.Nanosecond, "n"},
+		{time.Microsecond, "u"},
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+	}
+	for _, u := range units {
+		if value := int64(d / u.unit); value < grpcTimeoutMax {
+			return strconv.FormatInt(value, 10) + u.shortcut
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Hour), 10) + "H"
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mauriciozanettisalomao/go-rest-client/client/clienttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoDeadlinePropagation(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		header         string
+		timeout        time.Duration
+		requestTimeout time.Duration
+		expected       time.Duration
+		expectedSent   bool
+	}{
+		{
+			name:         "remaining time of the context",
+			header:       "X-Request-Deadline",
+			timeout:      2 * time.Second,
+			expected:     2 * time.Second,
+			expectedSent: true,
+		},
+		{
+			name:           "request timeout shorter than the context",
+			header:         "X-Request-Deadline",
+			timeout:        2 * time.Second,
+			requestTimeout: 500 * time.Millisecond,
+			expected:       500 * time.Millisecond,
+			expectedSent:   true,
+		},
+		{
+			name:         "no deadline",
+			header:       "X-Request-Deadline",
+			expectedSent: false,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var value string
+			var sent bool
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				value = r.Header.Get(tc.header)
+				_, sent = r.Header[http.CanonicalHeaderKey(tc.header)]
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			// the time left is counted from the fake clock, which stands still
+			clock := clienttest.NewFakeClock(time.Now())
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1).
+				WithClock(clock).
+				WithRequestTimeout(tc.requestTimeout).
+				WithDeadlinePropagation(tc.header)
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, clock.Now().Add(tc.timeout))
+				defer cancel()
+			}
+
+			var result map[string]interface{}
+			_, err := m.Do(ctx, nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedSent, sent)
+			if !tc.expectedSent {
+				return
+			}
+			assertion.Equal(strconv.FormatInt(tc.expected.Milliseconds(), 10), value)
+		})
+	}
+}
+
+func TestGRPCTimeout(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		expected string
+	}{
+		{
+			name:     "nanoseconds",
+			duration: 1500 * time.Nanosecond,
+			expected: "1500n",
+		},
+		{
+			name:     "microseconds",
+			duration: 1500 * time.Millisecond,
+			expected: "1500000u",
+		},
+		{
+			name:     "milliseconds",
+			duration: 2 * time.Minute,
+			expected: "120000m",
+		},
+		{
+			name:     "minutes",
+			duration: 200000 * time.Hour,
+			expected: "12000000M",
+		},
+		{
+			name:     "hours",
+			duration: math.MaxInt64,
+			expected: "2562047H",
+		},
+		{
+			name:     "expired",
+			expected: "0n",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			assertion.Equal(tc.expected, grpcTimeout(tc.duration))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// statusDecoder decodes the bodies of the responses with a status, or a class of statuses.
+type statusDecoder struct {
+	decode    func(body []byte, target interface{}) error
+	newTarget func() interface{}
+}
+
+// WithStatusDecoder decodes the bodies of the responses whose status is in the class, e.g. 4 for
+// the 4xx statuses, or is the exact status, e.g. 404, with the decoder, an exact status taking
+// precedence over its class. A success response is decoded into the response target of the call.
+// The body of any other response is decoded into a target made by newTarget for the call, e.g.
+// func() interface{} { return &Problem{} }, which is then set in the Decoded field of the returned
+// *StatusError, the call failing as usual. Without newTarget, such bodies are left undecoded.
+func (r *RestClient) WithStatusDecoder(statusClass int, decoder func(body []byte, target interface{}) error, newTarget func() interface{}) *RestClient {
+	if r.statusDecoders == nil {
+		r.statusDecoders = make(map[int]statusDecoder)
+	}
+	r.statusDecoders[statusClass] = statusDecoder{decode: decoder, newTarget: newTarget}
+	return r
+}
+
+// statusDecoder returns the decoder set for the status, or for its class.
+func (r *RestClient) statusDecoder(status int64) (statusDecoder, bool) {
+	if decoder, ok := r.statusDecoders[int(status)]; ok {
+		return decoder, true
+	}
+	decoder, ok := r.statusDecoders[int(status/100)]
+	return decoder, ok
+}
+
+// decodeStatus decodes the body of a success response with the decoder set for its status, if
+// any, falling back to the response type of the client.
+func (r *RestClient) decodeStatus(ctx context.Context, url string, status int64, body []byte, response interface{}) error {
+	decoder, ok := r.statusDecoder(status)
+	if !ok {
+		return r.decode(ctx, url, body, response)
+	}
+	if err := decoder.decode(body, response); err != nil {
+		err = fmt.Errorf("decoding response with status %d: %w", status, err)
+		r.logger().ErrorContext(ctx, "failed to decode response",
+			"err", err,
+			"url", url,
+		)
+		return err
+	}
+	return nil
+}
+
+// statusError returns the error of a response whose status is not a success one, with its body
+// decoded with the decoder set for the status, if any.
+func (r *RestClient) statusError(ctx context.Context, url string, status int64, body []byte) *StatusError {
+	statusErr := &StatusError{Status: status, Body: body}
+	decoder, ok := r.statusDecoder(status)
+	if !ok || decoder.newTarget == nil {
+		return statusErr
+	}
+	target := decoder.newTarget()
+	if err := decoder.decode(body, target); err != nil {
+		r.logger().WarnContext(ctx, "failed to decode error response",
+			"err", err,
+			"url", url,
+			"status", status,
+		)
+		return statusErr
+	}
+	statusErr.Decoded = target
+	return statusErr
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type decodedUser struct {
+	Name string `json:"name"`
+}
+
+type decodedProblem struct {
+	XMLName xml.Name `xml:"problem"`
+	Code    string   `xml:"code"`
+}
+
+func TestDoStatusDecoder(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		status           int
+		body             string
+		expectedStatus   int64
+		expectedResponse decodedProblem
+		expectedDecoded  interface{}
+		expectedError    bool
+	}{
+		{
+			name:             "2xx decoded into the response with its decoder",
+			status:           http.StatusOK,
+			body:             `<problem><code>ok</code></problem>`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: decodedProblem{XMLName: xml.Name{Local: "problem"}, Code: "ok"},
+		},
+		{
+			name:            "4xx decoded into its target with its decoder",
+			status:          http.StatusNotFound,
+			body:            `{"name": "john"}`,
+			expectedStatus:  http.StatusNotFound,
+			expectedDecoded: &decodedUser{Name: "john"},
+			expectedError:   true,
+		},
+		{
+			name:            "exact status takes precedence over its class",
+			status:          http.StatusConflict,
+			body:            `<problem><code>conflict</code></problem>`,
+			expectedStatus:  http.StatusConflict,
+			expectedDecoded: &decodedProblem{XMLName: xml.Name{Local: "problem"}, Code: "conflict"},
+			expectedError:   true,
+		},
+		{
+			name:           "5xx left undecoded",
+			status:         http.StatusInternalServerError,
+			body:           `{"name": "john"}`,
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  true,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithStatusDecoder(2, xml.Unmarshal, nil).
+				WithStatusDecoder(4, json.Unmarshal, func() interface{} { return &decodedUser{} }).
+				WithStatusDecoder(http.StatusConflict, xml.Unmarshal, func() interface{} { return &decodedProblem{} })
+
+			var response decodedProblem
+			status, err := m.Do(context.Background(), nil, &response)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedResponse, response)
+			if !tc.expectedError {
+				assertion.NoError(err)
+				return
+			}
+			var statusErr *StatusError
+			if assertion.True(errors.As(err, &statusErr)) {
+				assertion.Equal(tc.expectedDecoded, statusErr.Decoded)
+			}
+		})
+	}
+}
+
+func TestDoStatusDecoderTargetPerCall(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"name": %q}`, r.URL.Query().Get("name"))
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithMaxAttempts(1).
+		WithStatusDecoder(4, json.Unmarshal, func() interface{} { return &decodedUser{} })
+
+	var wg sync.WaitGroup
+	decoded := make([]interface{}, 2)
+	for i, name := range []string{"john", "jane"} {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			status, err := m.Get(context.Background(), svr.URL+"?name="+name, nil)
+			assertion.Equal(int64(http.StatusBadRequest), status)
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) {
+				decoded[i] = statusErr.Decoded
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	// every call decodes into a target of its own
+	assertion.Equal([]interface{}{&decodedUser{Name: "john"}, &decodedUser{Name: "jane"}}, decoded)
+}
+
+func TestDoIntoStatusDecoder(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"name": "john"}`)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithStatusDecoder(4, json.Unmarshal, func() interface{} { return &decodedUser{} })
+
+	var buf bytes.Buffer
+	status, err := m.DoInto(context.Background(), nil, &buf)
+	assertion.Equal(int64(http.StatusNotFound), status)
+	assertion.Empty(buf.String())
+	var statusErr *StatusError
+	if assertion.True(errors.As(err, &statusErr)) {
+		assertion.Equal(&decodedUser{Name: "john"}, statusErr.Decoded)
+	}
+}
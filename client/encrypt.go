@@ -0,0 +1,95 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultEncryptionHeader = "X-Content-Encrypted"
+	defaultEncryptionValue  = "true"
+)
+
+// Encryptor encrypts request bodies, e.g. with a key shared with the server.
+type Encryptor interface {
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+}
+
+// WithRequestEncryptor encrypts the encoded request body with the encryptor before it is sent,
+// after the transform set with WithBodyTransform, and advertises it with the header set with
+// WithEncryptionHeader, X-Content-Encrypted: true by default. It runs again on every attempt.
+// Empty bodies and bodies streamed from an io.Reader are sent as they are.
+func (r *RestClient) WithRequestEncryptor(enc Encryptor) *RestClient {
+	r.encryptor = enc
+	return r
+}
+
+// WithEncryptionHeader sets the header advertising the request bodies encrypted with the
+// encryptor set with WithRequestEncryptor, and the response bodies to be decrypted with the
+// decryptor set with WithResponseDecryptor.
+func (r *RestClient) WithEncryptionHeader(name, value string) *RestClient {
+	r.encryptionHeader = name
+	r.encryptionValue = value
+	return r
+}
+
+// encryptBody encrypts a buffered body with the encryptor, when set, reporting whether it did.
+func (r *RestClient) encryptBody(body payload) (payload, bool, error) {
+	if r.encryptor == nil || body.streamed() || len(body.body) == 0 {
+		return body, false, nil
+	}
+	ciphertext, err := r.encryptor.Encrypt(body.body)
+	if err != nil {
+		return body, false, fmt.Errorf("encrypting request body: %w", err)
+	}
+	body.body = ciphertext
+	return body, true, nil
+}
+
+// setEncryptionHeader advertises the encryption of the request body.
+func (r *RestClient) setEncryptionHeader(req *http.Request) {
+	req.Header.Set(r.encryptionHeaderField())
+}
+
+// encryptionHeaderField returns the name and value of the header advertising encrypted bodies.
+func (r *RestClient) encryptionHeaderField() (string, string) {
+	if r.encryptionHeader == "" {
+		return defaultEncryptionHeader, defaultEncryptionValue
+	}
+	return r.encryptionHeader, r.encryptionValue
+}
+
+// ErrDecryption is wrapped by the errors of response bodies that cannot be decrypted.
+var ErrDecryption = errors.New("decrypting response body")
+
+// Decryptor decrypts response bodies, e.g. with a key shared with the server.
+type Decryptor interface {
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+}
+
+// WithResponseDecryptor decrypts the body of success responses advertised as encrypted with the
+// header set with WithEncryptionHeader, X-Content-Encrypted: true by default, before it is
+// transformed, validated and decoded. A body that cannot be decrypted fails the call with an
+// error wrapping ErrDecryption, which is not retried. Result.Body keeps the body as received.
+func (r *RestClient) WithResponseDecryptor(dec Decryptor) *RestClient {
+	r.decryptor = dec
+	return r
+}
+
+// decryptBody decrypts the response body with the decryptor, when set and the response is advertised as encrypted.
+func (r *RestClient) decryptBody(header http.Header, body []byte) ([]byte, error) {
+	if r.decryptor == nil {
+		return body, nil
+	}
+	name, value := r.encryptionHeaderField()
+	if !strings.EqualFold(strings.TrimSpace(header.Get(name)), value) {
+		return body, nil
+	}
+	plaintext, err := r.decryptor.Decrypt(body)
+	if err != nil {
+		return body, fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// xorEncryptor is a fake Encryptor prefixing the body with the number of the encryption and
+// XORing it with the key.
+type xorEncryptor struct {
+	key   byte
+	calls int
+	err   error
+}
+
+func (e *xorEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	e.calls++
+	ciphertext := []byte(fmt.Sprintf("%d:", e.calls))
+	for _, b := range plaintext {
+		ciphertext = append(ciphertext, b^e.key)
+	}
+	return ciphertext, nil
+}
+
+// decrypt reverses xorEncryptor, returning the number of the encryption and the plaintext.
+func decrypt(ciphertext string, key byte) (int, string) {
+	var n int
+	fmt.Sscanf(ciphertext, "%d:", &n)
+	return n, xorString(ciphertext[strings.Index(ciphertext, ":")+1:], key)
+}
+
+func TestDoRequestEncryptor(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		headerName    string
+		headerValue   string
+		encryptErr    error
+		expectedName  string
+		expectedValue string
+		expectedError string
+	}{
+		{
+			name:          "body encrypted on every attempt",
+			expectedName:  "X-Content-Encrypted",
+			expectedValue: "true",
+		},
+		{
+			name:          "custom header",
+			headerName:    "Content-Encryption",
+			headerValue:   "xor",
+			expectedName:  "Content-Encryption",
+			expectedValue: "xor",
+		},
+		{
+			name:          "encryption failure",
+			encryptErr:    errors.New("key expired"),
+			expectedError: "encrypting request body: key expired",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var (
+				bodies  []string
+				headers []http.Header
+			)
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				headers = append(headers, r.Header.Clone())
+				// the first attempt fails, so that the body is sent again
+				if len(bodies) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(2).
+				WithRequestEncryptor(&xorEncryptor{key: 0x2a, err: tc.encryptErr})
+			if tc.headerName != "" {
+				m = m.WithEncryptionHeader(tc.headerName, tc.headerValue)
+			}
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), map[string]string{"card": "4242"}, &result)
+			if tc.expectedError != "" {
+				assertion.ErrorContains(err, tc.expectedError)
+				assertion.Empty(bodies)
+				return
+			}
+			assertion.NoError(err)
+			// the body is encrypted again on every attempt
+			if assertion.Len(bodies, 2) {
+				for i, body := range bodies {
+					assertion.NotContains(body, "4242")
+					n, plaintext := decrypt(body, 0x2a)
+					assertion.Equal(i+1, n)
+					assertion.JSONEq(`{"card": "4242"}`, plaintext)
+					assertion.Equal(tc.expectedValue, headers[i].Get(tc.expectedName))
+				}
+			}
+		})
+	}
+}
+
+// xorDecryptor is a fake Decryptor XORing the body with the key, failing on bodies not starting with the magic byte.
+type xorDecryptor struct {
+	key byte
+}
+
+func (d xorDecryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || ciphertext[0] != '!' {
+		return nil, errors.New("missing magic byte")
+	}
+	plaintext := make([]byte, 0, len(ciphertext)-1)
+	for _, b := range ciphertext[1:] {
+		plaintext = append(plaintext, b^d.key)
+	}
+	return plaintext, nil
+}
+
+func TestDoResponseDecryptor(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		body          string
+		header        map[string]string
+		headerName    string
+		headerValue   string
+		expected      map[string]interface{}
+		expectedError error
+	}{
+		{
+			name:     "encrypted response decrypted",
+			body:     "!" + xorString(`{"card": "4242"}`, 0x2a),
+			header:   map[string]string{"X-Content-Encrypted": "true"},
+			expected: map[string]interface{}{"card": "4242"},
+		},
+		{
+			name:        "custom header",
+			body:        "!" + xorString(`{"card": "4242"}`, 0x2a),
+			header:      map[string]string{"Content-Encryption": "xor"},
+			headerName:  "Content-Encryption",
+			headerValue: "xor",
+			expected:    map[string]interface{}{"card": "4242"},
+		},
+		{
+			name:     "plain response left as it is",
+			body:     `{"card": "4242"}`,
+			expected: map[string]interface{}{"card": "4242"},
+		},
+		{
+			name:          "decryption failure",
+			body:          xorString(`{"card": "4242"}`, 0x2a),
+			header:        map[string]string{"X-Content-Encrypted": "true"},
+			expectedError: ErrDecryption,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				for key, value := range tc.header {
+					w.Header().Set(key, value)
+				}
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithResponseDecryptor(xorDecryptor{key: 0x2a})
+			if tc.headerName != "" {
+				m = m.WithEncryptionHeader(tc.headerName, tc.headerValue)
+			}
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			if tc.expectedError != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				assertion.ErrorContains(err, "missing magic byte")
+				// decrypting the same body again would fail the same way
+				assertion.Equal(1, calls)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func xorString(s string, key byte) string {
+	b := []byte(s)
+	for i := range b {
+		b[i] ^= key
+	}
+	return string(b)
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrFieldNotFound is returned by DoValue when the response has no value at the path.
+var ErrFieldNotFound = errors.New("field not found")
+
+// DoValue makes an HTTP request like Do and returns the value at the dot-separated path of the
+// JSON response, e.g. "data.items.0.name", where numeric segments index arrays, without having
+// to define a type for the response. Values are decoded as by encoding/json into an interface{}:
+// objects are map[string]interface{}, arrays []interface{} and numbers float64. A missing value
+// is reported with an error wrapping ErrFieldNotFound, along with the status of the response.
+func (r *RestClient) DoValue(ctx context.Context, request interface{}, path string) (interface{}, int64, error) {
+	var document interface{}
+	status, err := r.Do(ctx, request, &document)
+	if err != nil {
+		return nil, status, err
+	}
+	value, err := lookup(document, path)
+	return value, status, err
+}
+
+// lookup returns the value at the dot-separated path of the decoded JSON document.
+func lookup(document interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return document, nil
+	}
+	value := document
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			field, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+			}
+			value = field
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("%w: %s: no index %q in an array of %d elements", ErrFieldNotFound, path, segment, len(v))
+			}
+			value = v[index]
+		default:
+			parent := "the response"
+			if i > 0 {
+				parent = strings.Join(segments[:i], ".")
+			}
+			return nil, fmt.Errorf("%w: %s: %s is %s, not an object or array", ErrFieldNotFound, path, parent, jsonType(v))
+		}
+	}
+	return value, nil
+}
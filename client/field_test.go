@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoValue(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		body          string
+		status        int
+		path          string
+		expected      interface{}
+		expectedError string
+	}{
+		{
+			name:     "top level field",
+			body:     `{"id": 7, "name": "widget"}`,
+			path:     "name",
+			expected: "widget",
+		},
+		{
+			name:     "nested field",
+			body:     `{"data": {"owner": {"email": "jane@example.com"}}}`,
+			path:     "data.owner.email",
+			expected: "jane@example.com",
+		},
+		{
+			name:     "array element",
+			body:     `{"data": {"items": [{"id": 1}, {"id": 2}]}}`,
+			path:     "data.items.1.id",
+			expected: float64(2),
+		},
+		{
+			name:     "object value",
+			body:     `{"data": {"owner": {"id": 3}}}`,
+			path:     "data.owner",
+			expected: map[string]interface{}{"id": float64(3)},
+		},
+		{
+			name:     "null value",
+			body:     `{"deleted_at": null}`,
+			path:     "deleted_at",
+			expected: nil,
+		},
+		{
+			name:     "whole document",
+			body:     `[1, 2]`,
+			expected: []interface{}{float64(1), float64(2)},
+		},
+		{
+			name:          "missing key",
+			body:          `{"data": {}}`,
+			path:          "data.owner.email",
+			expectedError: "field not found: data.owner.email",
+		},
+		{
+			name:          "index out of range",
+			body:          `{"items": [1]}`,
+			path:          "items.3",
+			expectedError: `field not found: items.3: no index "3" in an array of 1 elements`,
+		},
+		{
+			name:          "path through a scalar",
+			body:          `{"name": "widget"}`,
+			path:          "name.first",
+			expectedError: "field not found: name.first: name is string, not an object or array",
+		},
+		{
+			name:          "error response",
+			body:          `{"message": "not found"}`,
+			status:        http.StatusNotFound,
+			path:          "message",
+			expectedError: "404",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+
+			value, status, err := m.DoValue(context.Background(), nil, tc.path)
+			if tc.expectedError != "" {
+				assertion.ErrorContains(err, tc.expectedError)
+				assertion.Nil(value)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(tc.expected, value)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// mapHeaders sets the fields of a struct response target tagged with `header:"Name"` from the
+// headers of the response, after the body is decoded. Missing headers leave their fields untouched.
+// Tagged fields can be strings, booleans, numbers, time.Duration and []string, which gets all the
+// values of the header; they are usually also tagged with `json:"-"` to be left out of the body.
+func mapHeaders(header http.Header, response interface{}) error {
+	v := reflect.ValueOf(response)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return mapStructHeaders(header, v.Elem())
+}
+
+func mapStructHeaders(header http.Header, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := mapStructHeaders(header, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name, ok := field.Tag.Lookup("header")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if err := setHeaderField(v.Field(i), values); err != nil {
+			return fmt.Errorf("mapping header %s into field %s: %w", name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+// setHeaderField converts the values of a header into the field.
+func setHeaderField(field reflect.Value, values []string) error {
+	value := values[0]
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		field.Set(reflect.ValueOf(append([]string(nil), values...)).Convert(field.Type()))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
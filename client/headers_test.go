@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pageInfo struct {
+	Total int `header:"X-Total-Count" json:"-"`
+}
+
+type itemPage struct {
+	pageInfo
+	Items      []item        `json:"items"`
+	Remaining  uint          `header:"X-RateLimit-Remaining" json:"-"`
+	RetryAfter time.Duration `header:"X-Retry-In" json:"-"`
+	Cached     bool          `header:"X-Cached" json:"-"`
+	Warnings   []string      `header:"Warning" json:"-"`
+	ETag       string        `header:"ETag" json:"-"`
+}
+
+type badPage struct {
+	Total int `header:"X-Total-Count"`
+}
+
+func TestDoHeaderFields(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		headers       map[string][]string
+		response      func() interface{}
+		expected      interface{}
+		expectedError string
+	}{
+		{
+			name: "headers mapped into the tagged fields",
+			headers: map[string][]string{
+				"X-Total-Count":         {"42"},
+				"X-RateLimit-Remaining": {"7"},
+				"X-Retry-In":            {"1.5s"},
+				"X-Cached":              {"true"},
+				"Warning":               {"first", "second"},
+				"ETag":                  {`"v1"`},
+			},
+			response: func() interface{} { return &itemPage{} },
+			expected: &itemPage{
+				pageInfo:   pageInfo{Total: 42},
+				Items:      []item{{ID: 1}},
+				Remaining:  7,
+				RetryAfter: 1500 * time.Millisecond,
+				Cached:     true,
+				Warnings:   []string{"first", "second"},
+				ETag:       `"v1"`,
+			},
+		},
+		{
+			name:     "missing headers leave the fields untouched",
+			response: func() interface{} { return &itemPage{Remaining: 3} },
+			expected: &itemPage{Items: []item{{ID: 1}}, Remaining: 3},
+		},
+		{
+			name:          "invalid header value",
+			headers:       map[string][]string{"X-Total-Count": {"many"}},
+			response:      func() interface{} { return &badPage{} },
+			expectedError: "mapping header X-Total-Count into field Total",
+		},
+		{
+			name:     "non struct targets ignored",
+			headers:  map[string][]string{"X-Total-Count": {"42"}},
+			response: func() interface{} { return &map[string]interface{}{} },
+			expected: &map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": float64(1)}}},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range tc.headers {
+					w.Header()[key] = values
+				}
+				fmt.Fprint(w, `{"items": [{"id": 1}]}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+
+			response := tc.response()
+			_, err := m.Do(context.Background(), nil, response)
+			if tc.expectedError != "" {
+				assertion.ErrorContains(err, tc.expectedError)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, response)
+		})
+	}
+}
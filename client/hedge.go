@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithHedging sends up to maxHedges extra copies of a request, each one once the previous copies
+// have not responded within delay, and uses whichever response comes first, canceling the other
+// copies. Only GET, HEAD and OPTIONS requests are hedged, as sending them more than once has no
+// side effects. Zero or a negative maxHedges disables hedging.
+func (r *RestClient) WithHedging(delay time.Duration, maxHedges int) *RestClient {
+	r.hedgeDelay = delay
+	r.maxHedges = maxHedges
+	return r
+}
+
+// hedgeable reports whether the request can be hedged.
+func (r *RestClient) hedgeable(req *http.Request) bool {
+	if r.maxHedges < 1 {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	// every copy needs a body of its own
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// hedge is the outcome of a copy of a hedged request.
+type hedge struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+// hedge sends the request with the client, hedging it when enabled.
+func (r *RestClient) hedge(client *http.Client, req *http.Request) (*http.Response, error) {
+	if !r.hedgeable(req) {
+		return client.Do(req)
+	}
+
+	// buffered so that the copies never block once the first response is taken
+	outcomes := make(chan hedge, r.maxHedges+1)
+	var cancels []context.CancelFunc
+	launch := func() error {
+		ctx, cancel := context.WithCancel(req.Context())
+		copied := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			copied.Body = body
+		}
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := client.Do(copied)
+			outcomes <- hedge{index: index, resp: resp, err: err}
+		}()
+		return nil
+	}
+
+	if err := launch(); err != nil {
+		return nil, err
+	}
+	inFlight := 1
+	timer := time.NewTimer(r.hedgeDelay)
+	defer timer.Stop()
+
+	var winner hedge
+	for winner.resp == nil && winner.err == nil {
+		select {
+		case outcome := <-outcomes:
+			inFlight--
+			// a failed copy only ends the call when no other one may still succeed
+			if outcome.err != nil && inFlight > 0 {
+				cancels[outcome.index]()
+				continue
+			}
+			winner = outcome
+		case <-timer.C:
+			if len(cancels) > r.maxHedges {
+				continue
+			}
+			if err := launch(); err == nil {
+				inFlight++
+			}
+			timer.Reset(r.hedgeDelay)
+		}
+	}
+
+	// the other copies are canceled and their responses, if any, discarded
+	for i, cancel := range cancels {
+		if i != winner.index {
+			cancel()
+		}
+	}
+	go func(n int) {
+		for i := 0; i < n; i++ {
+			if outcome := <-outcomes; outcome.resp != nil {
+				outcome.resp.Body.Close()
+			}
+		}
+	}(inFlight)
+
+	cancel := cancels[winner.index]
+	if winner.err != nil {
+		cancel()
+		return winner.resp, winner.err
+	}
+	// the winning copy is canceled once the caller is done with its body
+	winner.resp.Body = &releaseReadCloser{ReadCloser: winner.resp.Body, release: cancel}
+	return winner.resp, nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoHedging(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		method           string
+		maxHedges        int
+		expectedCalls    int32
+		expectedCanceled bool
+	}{
+		{
+			name:             "slow request hedged",
+			method:           http.MethodGet,
+			maxHedges:        2,
+			expectedCalls:    2,
+			expectedCanceled: true,
+		},
+		{
+			name:          "hedging disabled",
+			method:        http.MethodGet,
+			expectedCalls: 1,
+		},
+		{
+			name:          "non idempotent request not hedged",
+			method:        http.MethodPost,
+			maxHedges:     2,
+			expectedCalls: 1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			baseline := runtime.NumGoroutine()
+
+			var calls int32
+			canceled := make(chan struct{})
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				// the first request is slow, the following ones are fast
+				if atomic.AddInt32(&calls, 1) == 1 {
+					select {
+					case <-r.Context().Done():
+						close(canceled)
+						return
+					case <-time.After(time.Millisecond * 300):
+					}
+				}
+				fmt.Fprintf(w, `{"call": %d}`, atomic.LoadInt32(&calls))
+			}))
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod(tc.method).
+				WithMaxAttempts(1).
+				WithHedging(time.Millisecond*50, tc.maxHedges)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(tc.expectedCalls, atomic.LoadInt32(&calls))
+
+			if tc.expectedCanceled {
+				// the hedge won, and the first request was canceled
+				assertion.Equal(map[string]interface{}{"call": float64(2)}, result)
+				select {
+				case <-canceled:
+				case <-time.After(time.Second):
+					t.Fatal("the slow request was not canceled")
+				}
+			}
+
+			svr.Close()
+			m.Close()
+			// polled by hand, as assert.Eventually runs its own goroutines
+			deadline := time.Now().Add(2 * time.Second)
+			for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			assertion.LessOrEqual(runtime.NumGoroutine(), baseline)
+		})
+	}
+}
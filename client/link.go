@@ -0,0 +1,72 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// parseLinks parses the RFC 5988 Link headers into a map of relation types to URLs,
+// e.g. `<https://api.example.com/items?page=2>; rel="next"` into {"next": "https://api.example.com/items?page=2"}.
+// A link with several relation types is stored under each of them; the first link of a type wins.
+// Relative targets, e.g. `</items?page=2>`, are resolved against the base, the URL of the request
+// the links answer, as RFC 8288 requires; they are kept as is without a base.
+func parseLinks(header http.Header, base *url.URL) map[string]string {
+	var links map[string]string
+	for _, value := range header.Values("Link") {
+		for {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			target := resolveLink(base, value[start+1:start+end])
+			value = value[start+end+1:]
+
+			// the parameters run up to the next link, which starts after a comma
+			params := value
+			if next := strings.IndexByte(value, '<'); next >= 0 {
+				params = value[:next]
+			}
+			params = strings.TrimSuffix(strings.TrimSpace(params), ",")
+			for _, param := range strings.Split(params, ";") {
+				name, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, relType := range strings.Fields(strings.Trim(strings.TrimSpace(rel), `"`)) {
+					if links == nil {
+						links = map[string]string{}
+					}
+					relType = strings.ToLower(relType)
+					if _, ok := links[relType]; !ok {
+						links[relType] = target
+					}
+				}
+			}
+		}
+	}
+	return links
+}
+
+// resolveLink resolves the target of a link against the base URL, leaving the target as is
+// without a base or when it cannot be parsed.
+func resolveLink(base *url.URL, target string) string {
+	if base == nil {
+		return target
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// NextLink returns the absolute URL of the next page given by the rel="next" Link header of the last
+// response, or an empty string on the last page.
+func (r *Result) NextLink() string {
+	return r.Links["next"]
+}
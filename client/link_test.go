@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLinks(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		links    []string
+		base     string
+		expected map[string]string
+	}{
+		{
+			name: "several relation types",
+			links: []string{`<https://api.example.com/items?page=3&per_page=10>; rel="next", ` +
+				`<https://api.example.com/items?page=10&per_page=10>; rel="last", ` +
+				`<https://api.example.com/items?page=1&per_page=10>; rel="first", ` +
+				`<https://api.example.com/items?page=1&per_page=10>; rel="prev"`},
+			expected: map[string]string{
+				"next":  "https://api.example.com/items?page=3&per_page=10",
+				"last":  "https://api.example.com/items?page=10&per_page=10",
+				"first": "https://api.example.com/items?page=1&per_page=10",
+				"prev":  "https://api.example.com/items?page=1&per_page=10",
+			},
+		},
+		{
+			name:  "several headers, parameters and unquoted rel",
+			links: []string{`</items?page=2>; title="Page 2"; rel=next`, `</items?cursor=a,b>; REL="Last alternate"`},
+			expected: map[string]string{
+				"next":      "/items?page=2",
+				"last":      "/items?cursor=a,b",
+				"alternate": "/items?cursor=a,b",
+			},
+		},
+		{
+			name:  "relative targets resolved against the base",
+			links: []string{`</items?page=2>; rel="next", <?page=9>; rel="last", <../users>; rel="related"`},
+			base:  "https://api.example.com/v1/items?page=1",
+			expected: map[string]string{
+				"next":    "https://api.example.com/items?page=2",
+				"last":    "https://api.example.com/v1/items?page=9",
+				"related": "https://api.example.com/users",
+			},
+		},
+		{
+			name:  "absolute target kept with a base",
+			links: []string{`<https://other.example.com/items?page=2>; rel="next"`},
+			base:  "https://api.example.com/v1/items",
+			expected: map[string]string{
+				"next": "https://other.example.com/items?page=2",
+			},
+		},
+		{
+			name:  "link without rel",
+			links: []string{`<https://api.example.com/items>; title="items"`},
+		},
+		{
+			name: "no link",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			header := http.Header{}
+			for _, link := range tc.links {
+				header.Add("Link", link)
+			}
+			var base *url.URL
+			if tc.base != "" {
+				var err error
+				base, err = url.Parse(tc.base)
+				assertion.NoError(err)
+			}
+			assertion.Equal(tc.expected, parseLinks(header, base))
+		})
+	}
+}
+
+func TestDoNextLink(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "2" {
+			w.Header().Set("Link", `</items?page=2>; rel="next", </items?page=2>; rel="last"`)
+		}
+		fmt.Fprint(w, `["item"]`)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMaxAttempts(1)
+
+	var pages int
+	for url := svr.URL; url != ""; pages++ {
+		var items []string
+		res, err := m.WithURL(url).DoResult(context.Background(), nil, &items)
+		assertion.NoError(err)
+		assertion.Equal([]string{"item"}, items)
+		url = res.NextLink()
+	}
+	assertion.Equal(2, pages)
+}
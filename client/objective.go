@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// WithLatencyObjective sets a callback invoked with the latency of every successful call taking
+// longer than the objective, retries included, to flag upstreams slow but still up, e.g. for SLO
+// monitoring. The latency of DoInto and DoJSONStream lasts until the body is fully read. Failed
+// calls are left to the error handling.
+func (r *RestClient) WithLatencyObjective(objective time.Duration, onBreach func(actual time.Duration)) *RestClient {
+	r.latencyObjective = objective
+	r.onLatencyBreach = onBreach
+	return r
+}
+
+// checkLatency reports the latency of a successful call started at the given time when it
+// exceeds the objective set with WithLatencyObjective.
+func (r *RestClient) checkLatency(ctx context.Context, url string, started time.Time) {
+	if r.onLatencyBreach == nil {
+		return
+	}
+	if actual := r.now().Sub(started); actual > r.latencyObjective {
+		r.logger().WarnContext(ctx, "latency objective breached",
+			"url", url,
+			"latency", actual,
+			"objective", r.latencyObjective,
+		)
+		r.onLatencyBreach(actual)
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mauriciozanettisalomao/go-rest-client/client/clienttest"
+)
+
+func TestDoLatencyObjective(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		into             bool
+		latency          time.Duration
+		statusCode       int
+		objective        time.Duration
+		expectedBreaches []time.Duration
+	}{
+		{
+			name:             "slow successful call",
+			latency:          50 * time.Millisecond,
+			statusCode:       http.StatusOK,
+			objective:        20 * time.Millisecond,
+			expectedBreaches: []time.Duration{50 * time.Millisecond},
+		},
+		{
+			name:       "fast successful call",
+			latency:    10 * time.Millisecond,
+			statusCode: http.StatusOK,
+			objective:  20 * time.Millisecond,
+		},
+		{
+			name:       "slow failed call",
+			latency:    50 * time.Millisecond,
+			statusCode: http.StatusNotFound,
+			objective:  20 * time.Millisecond,
+		},
+		{
+			name:             "slow successful DoInto call",
+			into:             true,
+			latency:          50 * time.Millisecond,
+			statusCode:       http.StatusOK,
+			objective:        20 * time.Millisecond,
+			expectedBreaches: []time.Duration{50 * time.Millisecond},
+		},
+		{
+			name:       "slow failed DoInto call",
+			into:       true,
+			latency:    50 * time.Millisecond,
+			statusCode: http.StatusNotFound,
+			objective:  20 * time.Millisecond,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			clock := clienttest.NewFakeClock(time.Now())
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(tc.latency)
+				w.WriteHeader(tc.statusCode)
+				fmt.Fprint(w, `{"message": "done"}`)
+			}))
+			defer svr.Close()
+
+			var breaches []time.Duration
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithClock(clock).
+				WithLatencyObjective(tc.objective, func(actual time.Duration) {
+					breaches = append(breaches, actual)
+				})
+
+			if tc.into {
+				var buf bytes.Buffer
+				m.DoInto(context.Background(), nil, &buf)
+			} else {
+				var result map[string]interface{}
+				m.Do(context.Background(), nil, &result)
+			}
+			assertion.Equal(tc.expectedBreaches, breaches)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WithObservableBody logs the body of every response at debug level, up to limit bytes and passed
+// through redact, when set, to mask secrets. A longer body is logged with a trailing
+// "...[truncated N bytes]" marker counting the bytes left out. The body is captured while it is
+// read for decoding, so it is neither read twice nor lost for the decoder. Zero or a negative
+// limit disables it.
+func (r *RestClient) WithObservableBody(limit int, redact func(body []byte) []byte) *RestClient {
+	r.observeLimit = limit
+	r.observeRedact = redact
+	return r
+}
+
+// cappedBuffer keeps the first bytes written to it, up to its limit, and counts the rest.
+type cappedBuffer struct {
+	limit   int
+	data    []byte
+	written int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.data); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.data = append(b.data, p[:room]...)
+	}
+	b.written += int64(len(p))
+	// never fails, so that capturing the body cannot break reading it
+	return len(p), nil
+}
+
+// observeBody tees the reader of the response body into a capped buffer when body logging is
+// enabled. The returned function logs the captured body once the reader has been consumed.
+func (r *RestClient) observeBody(ctx context.Context, resp *http.Response, reader io.Reader) (io.Reader, func()) {
+	if r.observeLimit <= 0 {
+		return reader, func() {}
+	}
+	captured := &cappedBuffer{limit: r.observeLimit}
+	return io.TeeReader(reader, captured), func() {
+		body := captured.data
+		if r.observeRedact != nil {
+			body = r.observeRedact(body)
+		}
+		logged := string(body)
+		// a cut body is marked as such, so that it is not mistaken for the whole one
+		truncated := captured.written - int64(len(captured.data))
+		if truncated > 0 {
+			logged += fmt.Sprintf("...[truncated %d bytes]", truncated)
+		}
+		r.logger().DebugContext(ctx, "response body",
+			"status", resp.StatusCode,
+			"body", logged,
+			"size", captured.written,
+			"truncated", truncated > 0,
+		)
+	}
+}
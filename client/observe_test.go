@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoObservableBody(t *testing.T) {
+
+	tests := []struct {
+		name              string
+		limit             int
+		redact            func(body []byte) []byte
+		expectedBody      string
+		expectedTruncated bool
+		expectedLogged    bool
+	}{
+		{
+			name:           "body logged and decoded",
+			limit:          1024,
+			expectedBody:   `{"user": "john", "token": "secret"}`,
+			expectedLogged: true,
+		},
+		{
+			name:  "body redacted",
+			limit: 1024,
+			redact: func(body []byte) []byte {
+				return bytes.ReplaceAll(body, []byte("secret"), []byte("***"))
+			},
+			expectedBody:   `{"user": "john", "token": "***"}`,
+			expectedLogged: true,
+		},
+		{
+			name:              "body capped",
+			limit:             10,
+			expectedBody:      `{"user": "...[truncated 25 bytes]`,
+			expectedTruncated: true,
+			expectedLogged:    true,
+		},
+		{
+			name:  "redacted body capped",
+			limit: 34,
+			redact: func(body []byte) []byte {
+				return bytes.ReplaceAll(body, []byte("secret"), []byte("***"))
+			},
+			expectedBody:      `{"user": "john", "token": "***"...[truncated 1 bytes]`,
+			expectedTruncated: true,
+			expectedLogged:    true,
+		},
+		{
+			name: "body logging disabled",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			buf := captureLogs(t)
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"user": "john", "token": "secret"}`)
+			}))
+			defer svr.Close()
+
+			var received int64
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithDownloadProgress(func(read, total int64) { received = read }).
+				WithObservableBody(tc.limit, tc.redact)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(map[string]interface{}{"user": "john", "token": "secret"}, result)
+			// the body was read from the connection once, for both the log and the decoder
+			assertion.Equal(int64(35), received)
+
+			var logged []map[string]interface{}
+			for _, record := range logRecords(t, buf) {
+				if record["msg"] == "response body" {
+					logged = append(logged, record)
+				}
+			}
+			if !tc.expectedLogged {
+				assertion.Empty(logged)
+				return
+			}
+			if assertion.Len(logged, 1) {
+				assertion.Equal(tc.expectedBody, logged[0]["body"])
+				assertion.Equal(float64(35), logged[0]["size"])
+				assertion.Equal(tc.expectedTruncated, logged[0]["truncated"])
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPreflight is returned, wrapping the error of the check, when the check set with WithPreflight fails.
+var ErrPreflight = errors.New("preflight check failed")
+
+// WithPreflight sets a check run once per call before the first attempt, e.g. a HEAD request to
+// the base URL probing whether the upstream is ready. When it fails, the call returns right away
+// with an error wrapping ErrPreflight and the request is not sent.
+func (r *RestClient) WithPreflight(check func(ctx context.Context) error) *RestClient {
+	r.preflight = check
+	return r
+}
+
+// preflightCheck runs the check set with WithPreflight, if any.
+func (r *RestClient) preflightCheck(ctx context.Context) error {
+	if r.preflight == nil {
+		return nil
+	}
+	if err := r.preflight(ctx); err != nil {
+		err = fmt.Errorf("%w: %w", ErrPreflight, err)
+		r.logger().ErrorContext(ctx, "preflight check failed",
+			"err", err,
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoPreflight(t *testing.T) {
+
+	errDown := errors.New("upstream down")
+
+	tests := []struct {
+		name           string
+		preflightErr   error
+		statusCode     int
+		expectedStatus int64
+		expectedCalls  int
+		expectedErr    error
+	}{
+		{
+			name:           "failing preflight prevents the request",
+			preflightErr:   errDown,
+			statusCode:     http.StatusOK,
+			expectedStatus: internalStatusRequestError,
+			expectedErr:    errDown,
+		},
+		{
+			name:           "passing preflight",
+			statusCode:     http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedCalls:  1,
+		},
+		{
+			name:           "run once before the retries",
+			statusCode:     http.StatusServiceUnavailable,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCalls:  3,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(tc.statusCode)
+				fmt.Fprint(w, `{"message": "done"}`)
+			}))
+			defer svr.Close()
+
+			var preflights int
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithIntervalSeconds(0).
+				WithPreflight(func(ctx context.Context) error {
+					preflights++
+					return tc.preflightErr
+				})
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(1, preflights)
+			if tc.expectedErr != nil {
+				assertion.ErrorIs(err, ErrPreflight)
+				assertion.ErrorIs(err, tc.expectedErr)
+			}
+		})
+	}
+}
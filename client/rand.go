@@ -0,0 +1,46 @@
+package client
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedRand is a random number generator safe for concurrent use.
+type lockedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rnd.Intn(n)
+}
+
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rnd.Int63n(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rnd.Float64()
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// random returns the random number generator of the client, seeding it
+// from the current time when WithSeed has not been used.
+func (r *RestClient) random() *lockedRand {
+	r.rndOnce.Do(func() {
+		if r.rnd == nil {
+			r.rnd = newLockedRand(time.Now().UnixNano())
+		}
+	})
+	return r.rnd
+}
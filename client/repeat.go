@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Summary aggregates the outcome of the calls made by DoN.
+type Summary struct {
+	// Requests is the number of calls made, which is less than requested when the context was done first.
+	Requests int
+	// Successes is the number of calls that returned no error.
+	Successes int
+	// Errors is the number of calls that returned an error.
+	Errors int
+	// Statuses counts the calls by their status; the counts add up to Requests.
+	Statuses map[int64]int
+	// P50, P90 and P99 are percentiles of the duration of the calls, retries included.
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	// Max is the duration of the slowest call.
+	Max time.Duration
+}
+
+// DoN makes the request of the client n times, with no body and discarding the responses, running
+// up to concurrency calls at once, e.g. to warm up a service or to load test it. No more calls are
+// started once the context is done.
+func (r *RestClient) DoN(ctx context.Context, n int, concurrency int) Summary {
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		summary   = Summary{Statuses: make(map[int64]int)}
+		latencies []time.Duration
+	)
+
+	slots := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			start := r.now()
+			result, err := r.DoResult(ctx, nil, nil)
+			elapsed := r.now().Sub(start)
+
+			mu.Lock()
+			defer mu.Unlock()
+			summary.Requests++
+			if err != nil {
+				summary.Errors++
+			} else {
+				summary.Successes++
+			}
+			summary.Statuses[result.Status]++
+			latencies = append(latencies, elapsed)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	summary.P50 = percentile(latencies, 50)
+	summary.P90 = percentile(latencies, 90)
+	summary.P99 = percentile(latencies, 99)
+	if len(latencies) > 0 {
+		summary.Max = latencies[len(latencies)-1]
+	}
+	return summary
+}
+
+// percentile returns the nearest-rank percentile p of the sorted durations, zero when there are none.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
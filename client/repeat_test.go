@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoN(t *testing.T) {
+
+	tests := []struct {
+		name              string
+		n                 int
+		concurrency       int
+		expectedSuccesses int
+		expectedErrors    int
+		expectedStatuses  map[int64]int
+	}{
+		{
+			name:              "sequential calls",
+			n:                 10,
+			concurrency:       1,
+			expectedSuccesses: 7,
+			expectedErrors:    3,
+			expectedStatuses:  map[int64]int{200: 7, 404: 3},
+		},
+		{
+			name:              "concurrent calls",
+			n:                 30,
+			concurrency:       5,
+			expectedSuccesses: 20,
+			expectedErrors:    10,
+			expectedStatuses:  map[int64]int{200: 20, 404: 10},
+		},
+		{
+			name:             "no calls",
+			concurrency:      5,
+			expectedStatuses: map[int64]int{},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls, inFlight, maxInFlight int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				current := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					peak := atomic.LoadInt32(&maxInFlight)
+					if current <= peak || atomic.CompareAndSwapInt32(&maxInFlight, peak, current) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond * 5)
+
+				// every third call is not found
+				if atomic.AddInt32(&calls, 1)%3 == 0 {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+
+			summary := m.DoN(context.Background(), tc.n, tc.concurrency)
+			assertion.Equal(tc.n, summary.Requests)
+			assertion.Equal(tc.expectedSuccesses, summary.Successes)
+			assertion.Equal(tc.expectedErrors, summary.Errors)
+			assertion.Equal(tc.expectedStatuses, summary.Statuses)
+			assertion.LessOrEqual(atomic.LoadInt32(&maxInFlight), int32(tc.concurrency))
+
+			total := 0
+			for _, count := range summary.Statuses {
+				total += count
+			}
+			assertion.Equal(tc.n, total)
+			assertion.LessOrEqual(summary.P50, summary.P90)
+			assertion.LessOrEqual(summary.P90, summary.P99)
+			assertion.LessOrEqual(summary.P99, summary.Max)
+		})
+	}
+}
+
+func TestDoNCanceled(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 5 {
+			cancel()
+		}
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1)
+
+	summary := m.DoN(ctx, 100, 1)
+	assertion.Less(summary.Requests, 100)
+	assertion.Equal(int(atomic.LoadInt32(&calls)), summary.Requests)
+
+	total := 0
+	for _, count := range summary.Statuses {
+		total += count
+	}
+	assertion.Equal(summary.Requests, total)
+	assertion.Equal(summary.Requests, summary.Successes+summary.Errors)
+}
+
+func TestPercentile(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	assertion.Equal(time.Duration(0), percentile(nil, 50))
+	assertion.Equal(50*time.Millisecond, percentile(latencies, 50))
+	assertion.Equal(90*time.Millisecond, percentile(latencies, 90))
+	assertion.Equal(99*time.Millisecond, percentile(latencies, 99))
+	assertion.Equal(time.Millisecond, percentile(latencies[:1], 99))
+}
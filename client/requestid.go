@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// requestIDKey is the context key of the ID generated for a call.
+type requestIDKey struct{}
+
+// WithGeneratedRequestID generates an ID for every call, sent in the given request header on all
+// of its attempts and logged with them, to correlate them without an external tracing system.
+// The ID is returned in Result.RequestID unless the server returns one of its own.
+func (r *RestClient) WithGeneratedRequestID(headerName string) *RestClient {
+	r.generatedIDHeader = headerName
+	return r
+}
+
+// WithRequestIDGenerator sets the function generating the IDs of WithGeneratedRequestID,
+// random 128-bit hex strings by default.
+func (r *RestClient) WithRequestIDGenerator(generator func() string) *RestClient {
+	r.idGenerator = generator
+	return r
+}
+
+// withRequestID returns a context carrying a newly generated ID for the call, when enabled.
+func (r *RestClient) withRequestID(ctx context.Context) context.Context {
+	if r.generatedIDHeader == "" {
+		return ctx
+	}
+	generator := r.idGenerator
+	if generator == nil {
+		generator = randomRequestID
+	}
+	return context.WithValue(ctx, requestIDKey{}, generator())
+}
+
+// generatedRequestID returns the ID generated for the call, empty when none was.
+func generatedRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func randomRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithGeneratedRequestID(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Get("X-Correlation-Id"))
+		attempt := len(received)
+		mu.Unlock()
+		// the first attempt of every call fails
+		if attempt%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	generated := 0
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(2).
+		WithGeneratedRequestID("X-Correlation-Id").
+		WithRequestIDGenerator(func() string {
+			generated++
+			return fmt.Sprintf("id-%d", generated)
+		})
+
+	logs := captureLogs(t)
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		var response map[string]interface{}
+		result, err := m.DoResult(context.Background(), nil, &response)
+		assertion.NoError(err)
+		ids = append(ids, result.RequestID)
+	}
+
+	// the attempts of a call share its ID, while every call gets its own
+	assertion.Equal([]string{"id-1", "id-1", "id-2", "id-2"}, received)
+	assertion.Equal([]string{"id-1", "id-2"}, ids)
+
+	var retried []interface{}
+	for _, record := range logRecords(t, logs) {
+		if record["msg"] == "retrying request" {
+			retried = append(retried, record["request_id"])
+		}
+	}
+	assertion.Equal([]interface{}{"id-1", "id-2"}, retried)
+}
+
+func TestWithGeneratedRequestIDDefaultGenerator(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	m := NewRestClient().
+		WithURL("http://localhost/items").
+		WithMethod("GET").
+		WithGeneratedRequestID("X-Correlation-Id")
+
+	first, err := m.BuildRequest(context.Background(), nil)
+	assertion.NoError(err)
+	second, err := m.BuildRequest(context.Background(), nil)
+	assertion.NoError(err)
+
+	assertion.Len(first.Header.Get("X-Correlation-Id"), 32)
+	assertion.NotEqual(first.Header.Get("X-Correlation-Id"), second.Header.Get("X-Correlation-Id"))
+}
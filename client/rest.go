@@ -4,26 +4,218 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"math"
+	"mime"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	internalStatusRequestError = 999
+	defaultRequestIDHeader     = "X-Request-Id"
+	methodOverrideHeader       = "X-HTTP-Method-Override"
+	contentTypeSnippetSize     = 256
 )
 
+// ResponseType defines how the body of a success response is stored into the response target.
+type ResponseType int
+
+const (
+	// JSON decodes the body as JSON into the response target.
+	JSON ResponseType = iota
+	// Raw stores the body as it is into a *[]byte response target.
+	Raw
+	// Text stores the body as it is into a *string response target.
+	Text
+)
+
+// RetryDecision is the outcome of a retry classifier for an attempt.
+type RetryDecision int
+
+const (
+	// DoNotRetry ends the call with the outcome of the attempt.
+	DoNotRetry RetryDecision = iota
+	// Retry makes another attempt, unless the maximum number of attempts is reached.
+	Retry
+	// Abort ends the call with an error wrapping ErrAborted, even when the attempt succeeded.
+	Abort
+)
+
+// ErrAborted is returned when a retry classifier aborts the call.
+var ErrAborted = errors.New("call aborted")
+
+// ErrNoAttempts is returned when the call is not attempted at all, as its maximum number of attempts is below one.
+var ErrNoAttempts = errors.New("no attempts were made")
+
+// Retryable can be implemented by response types to drive the retries from the decoded body.
+// When the decoded response reports ShouldRetry, the call is retried up to the maximum number of attempts.
+type Retryable interface {
+	ShouldRetry() bool
+}
+
+// StatusError is returned when the response status is not one of the success statuses.
+type StatusError struct {
+	Status  int64
+	Body    []byte
+	Decoded interface{} // the body decoded with the decoder set with WithStatusDecoder, if any
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status %d", e.Status)
+}
+
+// ResponseError is returned when a request fails even though a response was received, e.g. when
+// the redirect it asks for goes past the limit set with WithMaxRedirects. It keeps the status,
+// headers and whatever could be read of the body of that response, to help debugging.
+type ResponseError struct {
+	Status int64
+	Header http.Header
+	Body   []byte
+	Err    error
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%v (response status %d)", e.Err, e.Status)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// responseError keeps the response the HTTP client returned along with an error, which it only
+// does when following a redirect fails. The body is usually closed by then, so it is read on a
+// best-effort basis.
+func responseError(resp *http.Response, err error) error {
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return &ResponseError{Status: int64(resp.StatusCode), Header: resp.Header, Body: body, Err: err}
+}
+
+// ErrTooManyRedirects is returned when a request goes past the limit set with WithMaxRedirects.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// ErrUnexpectedContentType is returned when the Content-Type of a success response does not
+// match the media type set with WithExpectContentType.
+var ErrUnexpectedContentType = errors.New("unexpected content type")
+
+// ErrEmptyBody is returned when a success response has an empty body under WithRequireNonEmptyBody.
+var ErrEmptyBody = errors.New("empty response body")
+
+// ErrRetryable can be wrapped by the error returned from a response validator
+// to have the response retried as if the server had returned a 5xx status.
+var ErrRetryable = errors.New("retryable response")
+
 // RestClient is a client that can make HTTP requests.
 type RestClient struct {
-	method          string
-	url             string
-	header          map[string]string
-	maxAttempts     int64
-	intervalSeconds float64
-	backoffRate     float64
-	timeout         time.Duration
+	name              string
+	logFields         map[string]interface{}
+	method            string
+	methodOverride    string
+	url               string
+	urls              []string
+	header            map[string]string
+	rawHeader         map[string]string
+	userAgent         string
+	retry             RetryPolicy
+	methodRetry       map[string]RetryPolicy
+	timeout           time.Duration // timeout of each attempt, enforced by the HTTP client
+	requestTimeout    time.Duration // timeout of each attempt, enforced by the request context
+	timeoutGrowth     float64
+	maxTimeout        time.Duration
+	totalTimeout      time.Duration // timeout of the whole call, retries and backoff included
+	requestHook       func(req *http.Request) error
+	preflight         func(ctx context.Context) error
+	latencyObjective  time.Duration
+	onLatencyBreach   func(actual time.Duration)
+	rawResponse       func(resp *http.Response) error
+	beforeRetry       func(ctx context.Context, attempt int64, prevStatus int64) error
+	onRetry           func(ctx context.Context, attempt int64, reason RetryReason, err error)
+	validator         func(status int64, body []byte) error
+	bodyTransform     func(ctx context.Context, body []byte) ([]byte, error)
+	encryptor         Encryptor
+	encryptionHeader  string
+	encryptionValue   string
+	decryptor         Decryptor
+	expectContinue    bool
+	responseTransform func(ctx context.Context, body []byte) ([]byte, error)
+	fallback          interface{}
+	encoder           func(v interface{}) ([]byte, string, error)
+	uploadProgress    func(sent, total int64)
+	downloadProgress  func(received, total int64)
+	observeLimit      int
+	observeRedact     func(body []byte) []byte
+	responseType      ResponseType
+	bulkhead          *bulkhead
+	decompressors     map[string]func(io.Reader) (io.Reader, error)
+	statusDecoders    map[int]statusDecoder
+	expectedType      string
+	requireBody       bool
+	sniffContentType  bool
+	schemaCompiler    SchemaCompiler
+	responseSchema    SchemaValidator
+	requestSchema     SchemaValidator
+	classifier        func(status int64, body []byte, err error) RetryDecision
+	successStatuses   []int
+	retryOnStatuses   []int
+	noRetryStatuses   []int
+	noBodyStatuses    []int
+	requestIDHeader   string
+	generatedIDHeader string
+	idGenerator       func() string
+	deadlineHeader    string
+	dryRun            bool
+	cache             *responseCache
+	maxRedirects      *int
+	maxResponseSize   int64
+	capturePartial    bool
+	proxy             string
+	proxyUsername     string
+	proxyPassword     string
+	headerTimeout     time.Duration
+	tlsTimeout        time.Duration
+	disableKeepAlives bool
+	dialOverride      string
+	hedgeDelay        time.Duration
+	maxHedges         int
+	failFastDNS       bool
+	retryBodyRead     bool
+	netErrors         []error
+	transport         *http.Transport
+	sharedTransport   bool
+	transportMu       sync.Mutex
+	lifetimeCtx       context.Context
+	baseCtx           context.Context
+	shutdown          context.CancelFunc
+	lifetimeOnce      sync.Once
+	balancer          *balancer
+	breaker           *breaker
+	breakerChange     func(from, to CircuitState)
+	latency           *latencyTracker
+	rnd               *lockedRand
+	clock             Clock
+	initialJitter     time.Duration
+	timeoutJitter     float64
+	rndOnce           sync.Once
+}
+
+// WithName sets a name that identifies the client in its log records.
+func (r *RestClient) WithName(name string) *RestClient {
+	r.name = name
+	return r
+}
+
+// WithLogFields sets fields, such as a tenant or an operation name, added to every log record of the client.
+func (r *RestClient) WithLogFields(fields map[string]interface{}) *RestClient {
+	r.logFields = fields
+	return r
 }
 
 // WithMethod sets the HTTP method for the request.
@@ -32,148 +224,1448 @@ func (r *RestClient) WithMethod(method string) *RestClient {
 	return r
 }
 
+// WithMethodOverride sends the request as a POST carrying the given method in the
+// X-HTTP-Method-Override header, for gateways that only accept GET and POST.
+func (r *RestClient) WithMethodOverride(actualMethod string) *RestClient {
+	r.methodOverride = actualMethod
+	return r
+}
+
 // WithURL sets the URL for the request.
 func (c *RestClient) WithURL(url string) *RestClient {
 	c.url = url
+	c.urls = nil
 	return c
 }
 
+// WithURLs sets several URLs for the request. Each attempt fails over to the next URL,
+// in the given order, wrapping around when the list is exhausted.
+func (r *RestClient) WithURLs(urls ...string) *RestClient {
+	r.urls = urls
+	if len(urls) > 0 {
+		r.url = urls[0]
+	}
+	return r
+}
+
+// WithLoadBalancer sets the strategy used to pick the URL of the first attempt of each call
+// among the URLs set with WithURLs. URLs that failed recently are temporarily skipped.
+func (r *RestClient) WithLoadBalancer(strategy LBStrategy) *RestClient {
+	r.balancer = newBalancer(strategy, r.now)
+	return r
+}
+
+// WithSeed seeds the random number generator of the client, making random choices reproducible,
+// e.g. the URLs picked by the Random load balancer and the jitter of the waits between attempts.
+func (r *RestClient) WithSeed(seed int64) *RestClient {
+	r.rnd = newLockedRand(seed)
+	return r
+}
+
 // WithHeader sets the headers for the request.
 func (r *RestClient) WithHeader(header map[string]string) *RestClient {
 	r.header = header
 	return r
 }
 
+// WithUserAgent sets the User-Agent header of the requests, unless set with WithHeader.
+func (r *RestClient) WithUserAgent(userAgent string) *RestClient {
+	r.userAgent = userAgent
+	return r
+}
+
+// WithRawHeaders sets headers whose names are sent exactly as given, bypassing the canonicalization
+// done by WithHeader (e.g. "x-api-key" is not turned into "X-Api-Key"), for legacy servers that
+// require a specific casing. Casing is only preserved over HTTP/1.x: HTTP/2 always sends lower-cased names.
+func (r *RestClient) WithRawHeaders(header map[string]string) *RestClient {
+	r.rawHeader = header
+	return r
+}
+
 // WithIntervalSeconds sets the interval between retries.
 func (r *RestClient) WithIntervalSeconds(intervalSeconds float64) *RestClient {
-	r.intervalSeconds = intervalSeconds
+	r.retry.IntervalSeconds = intervalSeconds
 	return r
 }
 
 // WithBackoffRate sets the backoff rate for retries.
 func (r *RestClient) WithBackoffRate(backoffRate float64) *RestClient {
-	r.backoffRate = backoffRate
+	r.retry.BackoffRate = backoffRate
 	return r
 }
 
-// WithMaxAttempts sets the maximum number of attempts.
+// WithMaxAttempts sets the maximum number of attempts. Calls fail with ErrNoAttempts when it is below one.
 func (r *RestClient) WithMaxAttempts(maxAttempts int64) *RestClient {
-	r.maxAttempts = maxAttempts
+	r.retry.MaxAttempts = maxAttempts
+	return r
+}
+
+// WithMaxBackoff caps the wait between retries, which otherwise grows with the backoff rate.
+func (r *RestClient) WithMaxBackoff(maxBackoff time.Duration) *RestClient {
+	r.retry.MaxBackoff = maxBackoff
+	return r
+}
+
+// WithMinInterval sets a floor to the wait between retries, raising any smaller computed backoff up to it.
+func (r *RestClient) WithMinInterval(minInterval time.Duration) *RestClient {
+	r.retry.MinInterval = minInterval
 	return r
 }
 
-// WithTimeout sets the timeout for the request.
+// WithTimeout sets the timeout of each attempt, enforced through the timeout of the HTTP client.
+// It is shortened to the time left before the deadline of the call context, when sooner.
+// An attempt that times out is retried.
 func (r *RestClient) WithTimeout(timeout time.Duration) *RestClient {
 	r.timeout = timeout
 	return r
 }
 
-// Do makes an HTTP request
+// WithRequestTimeout sets the timeout of each attempt, enforced through the context of the request.
+// Unlike WithTimeout, it interrupts a slow body read with a context.DeadlineExceeded error.
+// An attempt that times out is retried.
+func (r *RestClient) WithRequestTimeout(requestTimeout time.Duration) *RestClient {
+	r.requestTimeout = requestTimeout
+	return r
+}
+
+// WithTimeoutGrowth multiplies the timeouts of each attempt, set with WithTimeout and
+// WithRequestTimeout, by the factor on every retry, giving upstreams slow under load more time:
+// with a factor of 1.5, the second attempt gets 1.5 times the timeout and the third 2.25 times.
+func (r *RestClient) WithTimeoutGrowth(factor float64) *RestClient {
+	r.timeoutGrowth = factor
+	return r
+}
+
+// WithMaxTimeout caps the timeouts of each attempt grown with WithTimeoutGrowth.
+func (r *RestClient) WithMaxTimeout(max time.Duration) *RestClient {
+	r.maxTimeout = max
+	return r
+}
+
+// WithTimeoutJitter moves the timeouts of each attempt, set with WithTimeout and WithRequestTimeout,
+// by a random fraction of them, up to the given fraction either way, so that clients sharing the same
+// timeout do not all give up at once: with a fraction of 0.1, a 10s timeout lasts between 9s and 11s.
+// The jitter is drawn from the random number generator seeded with WithSeed, and applies after
+// WithTimeoutGrowth and WithMaxTimeout.
+func (r *RestClient) WithTimeoutJitter(fraction float64) *RestClient {
+	r.timeoutJitter = fraction
+	return r
+}
+
+// WithInitialJitter delays the first attempt of each call by a random duration between zero and
+// the given maximum, so instances starting at once do not send their first requests in sync.
+func (r *RestClient) WithInitialJitter(max time.Duration) *RestClient {
+	r.initialJitter = max
+	return r
+}
+
+// WithTotalTimeout sets the timeout of the whole call, including every attempt and the backoff
+// between them. Once it expires, no further attempts are made and the call fails with an error
+// wrapping ErrClientTimeout.
+func (r *RestClient) WithTotalTimeout(totalTimeout time.Duration) *RestClient {
+	r.totalTimeout = totalTimeout
+	return r
+}
+
+// WithBeforeRetry sets a hook that runs before each retry, but not before the first attempt.
+// It receives the number of the attempt about to be made and the status of the previous one,
+// and may mutate the client (e.g. switch the URL for failover). Returning an error aborts retrying.
+func (r *RestClient) WithBeforeRetry(beforeRetry func(ctx context.Context, attempt int64, prevStatus int64) error) *RestClient {
+	r.beforeRetry = beforeRetry
+	return r
+}
+
+// WithEncoder sets the function that serializes the request body, returning the encoded bytes
+// and their content type, which is sent in the Content-Type header. By default, the body is encoded
+// as JSON with json.Marshal.
+func (r *RestClient) WithEncoder(encoder func(v interface{}) ([]byte, string, error)) *RestClient {
+	r.encoder = encoder
+	return r
+}
+
+// WithBodyTransform sets a function transforming the encoded request body before it is sent,
+// e.g. to wrap it in an envelope, append a checksum or encrypt it. It runs again on every attempt,
+// and the Content-Length is the one of the transformed body. Bodies streamed from an io.Reader
+// are sent as they are.
+func (r *RestClient) WithBodyTransform(transform func(ctx context.Context, body []byte) ([]byte, error)) *RestClient {
+	r.bodyTransform = transform
+	return r
+}
+
+// WithResponseTransform sets a function transforming the body of success responses before it is
+// validated and decoded, e.g. to decrypt it or unwrap an envelope. Result.Body keeps the body as received.
+func (r *RestClient) WithResponseTransform(transform func(ctx context.Context, body []byte) ([]byte, error)) *RestClient {
+	r.responseTransform = transform
+	return r
+}
+
+// WithExpectContinue sends bodies streamed from an io.Reader with an Expect: 100-continue header,
+// so the server can reject the request before the body is sent. Such a rejection leaves the
+// reader untouched and the request is retried like any other; once the body has started being
+// sent, it cannot be retried. Without it, calls with a streamed body are never retried, as
+// nothing tells whether the server received part of the body.
+func (r *RestClient) WithExpectContinue() *RestClient {
+	r.expectContinue = true
+	return r
+}
+
+// WithFallbackResponse sets the value stored into the response target when a call ultimately
+// fails, e.g. after exhausting its retries. The call then returns no error and the StatusFallback
+// status, so that a served fallback is never mistaken for a response of the server; the Result of
+// DoResult reports the fallback and holds the error of the failed call. Each call gets its own copy
+// of the value, made through JSON. Calls making no attempt, e.g. failing to encode the request or
+// in dry-run mode, and calls after Shutdown still return their error.
+func (r *RestClient) WithFallbackResponse(v interface{}) *RestClient {
+	r.fallback = v
+	return r
+}
+
+// WithUploadProgress sets a callback reporting the bytes of the request body sent so far,
+// as they are consumed by the transport, and the total size, -1 when unknown.
+func (r *RestClient) WithUploadProgress(progress func(sent, total int64)) *RestClient {
+	r.uploadProgress = progress
+	return r
+}
+
+// WithDownloadProgress sets a callback reporting the bytes of the response body received so far
+// and the total size taken from the Content-Length header, -1 when unknown.
+func (r *RestClient) WithDownloadProgress(progress func(received, total int64)) *RestClient {
+	r.downloadProgress = progress
+	return r
+}
+
+// WithResponseType sets how the body of a success response is stored into the response target:
+// decoded as JSON, the default, or as it is into a *[]byte (Raw) or *string (Text) target.
+func (r *RestClient) WithResponseType(t ResponseType) *RestClient {
+	r.responseType = t
+	return r
+}
+
+// WithRequireNonEmptyBody fails the calls whose success response has an empty body, or one made
+// of whitespace only, with ErrEmptyBody instead of leaving the response target untouched.
+func (r *RestClient) WithRequireNonEmptyBody() *RestClient {
+	r.requireBody = true
+	return r
+}
+
+// WithExpectContentType sets the media type, e.g. "application/json", the Content-Type of success
+// responses must match. Any other type, like the HTML error page of a misconfigured endpoint,
+// fails the call with ErrUnexpectedContentType before the body is decoded.
+func (r *RestClient) WithExpectContentType(mediaType string) *RestClient {
+	r.expectedType = mediaType
+	return r
+}
+
+// WithContentTypeSniffing makes the media type set with WithExpectContentType be guessed from the
+// body when the response has no Content-Type or an application/octet-stream one: a body starting
+// with { or [ is taken as application/json.
+func (r *RestClient) WithContentTypeSniffing() *RestClient {
+	r.sniffContentType = true
+	return r
+}
+
+// WithRetryClassifier sets the function deciding, after each attempt, whether the call is retried,
+// ended with the outcome of the attempt, or aborted. It receives the status, the body and the error
+// of the attempt, after the response has been validated and decoded, and replaces the default
+// classification, which retries 5xx statuses, the statuses set with WithRetryOn, errors wrapping
+// ErrRetryable and Retryable responses.
+func (r *RestClient) WithRetryClassifier(classifier func(status int64, body []byte, err error) RetryDecision) *RestClient {
+	r.classifier = classifier
+	return r
+}
+
+// WithSuccessStatusCodes sets the statuses treated as success, 2xx by default. Only the body
+// of a success response is decoded into the response target; any other status is returned
+// along with a *StatusError holding the body, except for redirects without a Location header,
+// which cannot be followed and are returned as they are, with their body in Result.Body.
+func (r *RestClient) WithSuccessStatusCodes(codes ...int) *RestClient {
+	r.successStatuses = codes
+	return r
+}
+
+// WithRetryOn adds statuses to be retried on top of the 5xx ones, e.g. 429 Too Many Requests.
+func (r *RestClient) WithRetryOn(codes ...int) *RestClient {
+	r.retryOnStatuses = codes
+	return r
+}
+
+// WithNonRetryableStatuses sets statuses returned right away, without retrying, even when they are
+// 5xx ones, e.g. 501 Not Implemented, which no retry can fix. They take precedence over WithRetryOn.
+func (r *RestClient) WithNonRetryableStatuses(codes ...int) *RestClient {
+	r.noRetryStatuses = codes
+	return r
+}
+
+// WithNoBodyStatuses sets the statuses whose response body is neither read nor decoded, 204 No
+// Content and 304 Not Modified by default. The body of the responses to HEAD requests is never read
+// either. Statuses other than success ones still fail the call with a *StatusError, without a body.
+// Without codes, every body is read.
+func (r *RestClient) WithNoBodyStatuses(codes ...int) *RestClient {
+	r.noBodyStatuses = append([]int{}, codes...)
+	return r
+}
+
+// WithRequestIDHeader sets the response header carrying the correlation ID of the request,
+// X-Request-Id by default. The ID is logged with the outcome of each attempt and returned in the Result.
+func (r *RestClient) WithRequestIDHeader(name string) *RestClient {
+	r.requestIDHeader = name
+	return r
+}
+
+// WithResponseValidator sets a function that validates the response body before it is decoded,
+// so semantically invalid responses (e.g. an error payload with a 200 status) are surfaced as errors.
+// Errors wrapping ErrRetryable are retried, up to the maximum number of attempts.
+func (r *RestClient) WithResponseValidator(validator func(status int64, body []byte) error) *RestClient {
+	r.validator = validator
+	return r
+}
+
+// BuildRequest builds the request the client would send first for the given request body,
+// without sending it, e.g. to assert on its URL, headers and body in tests. The request is
+// built the same way as with WithDryRun, starting from the URL the load balancer picks for
+// the call; an io.Reader body is not copied, so reading the body of the built request consumes it.
+func (r *RestClient) BuildRequest(ctx context.Context, request interface{}) (*http.Request, error) {
+	body, err := r.encode(request)
+	if err != nil {
+		return nil, err
+	}
+	return r.newRequest(r.withRequestID(ctx), r.method, r.attemptURL(r.startIndex()), nil, body)
+}
+
+// WithDryRun makes calls build the request without sending it. The built request is
+// returned in the Result along with the StatusDryRun status.
+func (r *RestClient) WithDryRun() *RestClient {
+	r.dryRun = true
+	return r
+}
+
+// WithCache keeps GET responses carrying an ETag or Last-Modified header and revalidates them
+// with conditional requests, serving the cached body when the server answers 304 Not Modified.
+// Responses are kept apart by URL and by the Authorization, Cookie, Accept and Accept-Language
+// headers of the request, so that callers with other credentials do not share them, and only the
+// 1000 most recently used ones are kept.
+func (r *RestClient) WithCache() *RestClient {
+	r.cache = newResponseCache(defaultCacheEntries)
+	return r
+}
+
+// WithMaxRedirects sets the maximum number of redirects followed by each attempt.
+// Zero disables following redirects, returning the first 3xx response as is; going past
+// a positive limit fails with ErrTooManyRedirects.
+func (r *RestClient) WithMaxRedirects(maxRedirects int) *RestClient {
+	r.maxRedirects = &maxRedirects
+	return r
+}
+
+// WithMaxResponseSize sets the maximum size, in bytes, of the response body after decompression.
+// Do fails on larger responses with ErrResponseTooLarge, without decoding anything, while DoInto
+// and DoJSONStream deliver the body up to the limit and then return ErrTruncated.
+func (r *RestClient) WithMaxResponseSize(maxResponseSize int64) *RestClient {
+	r.maxResponseSize = maxResponseSize
+	return r
+}
+
+// WithCapturePartialBody keeps the bytes read before reading a response body fails,
+// e.g. on a timeout, and returns them in Result.PartialBody for diagnostics.
+func (r *RestClient) WithCapturePartialBody() *RestClient {
+	r.capturePartial = true
+	return r
+}
+
+// WithProxy sets the URL of the proxy the requests go through.
+func (r *RestClient) WithProxy(proxyURL string) *RestClient {
+	r.proxy = proxyURL
+	r.resetTransport()
+	return r
+}
+
+// WithProxyBasicAuth sets the credentials sent to the proxy set with WithProxy, on the
+// CONNECT request for HTTPS and on the request itself for plain HTTP.
+func (r *RestClient) WithProxyBasicAuth(username, password string) *RestClient {
+	r.proxyUsername = username
+	r.proxyPassword = password
+	r.resetTransport()
+	return r
+}
+
+// WithResponseHeaderTimeout sets how long to wait for the response headers once the request
+// is sent. Unlike WithTimeout, it does not bound reading the body, so streamed responses can
+// take as long as they need once the server started responding.
+func (r *RestClient) WithResponseHeaderTimeout(timeout time.Duration) *RestClient {
+	r.headerTimeout = timeout
+	r.resetTransport()
+	return r
+}
+
+// WithDisableKeepAlives opens a new connection for every request instead of reusing pooled ones,
+// e.g. to spread calls across the instances behind a load balancer.
+func (r *RestClient) WithDisableKeepAlives() *RestClient {
+	r.disableKeepAlives = true
+	r.resetTransport()
+	return r
+}
+
+// WithDialOverride connects to the given host:port instead of the host of the URL, e.g. to reach a
+// local instance of a service, while the requests keep the Host header and TLS server name of the URL.
+func (r *RestClient) WithDialOverride(hostport string) *RestClient {
+	r.dialOverride = hostport
+	r.resetTransport()
+	return r
+}
+
+// WithTLSHandshakeTimeout sets how long to wait for the TLS handshake with the server,
+// instead of the 10 seconds of the default transport.
+func (r *RestClient) WithTLSHandshakeTimeout(timeout time.Duration) *RestClient {
+	r.tlsTimeout = timeout
+	r.resetTransport()
+	return r
+}
+
+// Do makes an HTTP request. The request is encoded as the body, unless it is an io.Reader,
+// which is streamed as is and, since it can only be read once, only retried when the attempt
+// failed before reading from it (see WithExpectContinue). An io.ReadCloser, e.g. a file, is
+// closed once the call is done; when its size is known and up to 1MB, it is read into memory
+// first so that it can be retried like an encoded body. The fields of a struct response tagged
+// with `header:"Name"` are set from the headers of the response once the body is decoded.
 func (r *RestClient) Do(ctx context.Context, request interface{}, response interface{}) (int64, error) {
+	result, err := r.DoResult(ctx, request, response)
+	return result.Status, err
+}
+
+// DoAsync makes an HTTP request like DoResult in its own goroutine, returning a channel receiving
+// the Result, with the error of the call in Err, and a function canceling the call. The channel is
+// buffered, so the goroutine finishes even when the Result is never received.
+func (r *RestClient) DoAsync(ctx context.Context, request interface{}, response interface{}) (<-chan Result, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	results := make(chan Result, 1)
+	go func() {
+		defer cancel()
+		result, err := r.DoResult(ctx, request, response)
+		if err != nil {
+			result.Err = err
+		}
+		results <- *result
+		close(results)
+	}()
+	return results, cancel
+}
+
+// DoWithTimeout makes an HTTP request like Do, bounding this call alone by the given timeout
+// without changing the timeouts configured on the client.
+func (r *RestClient) DoWithTimeout(ctx context.Context, timeout time.Duration, request interface{}, response interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return r.Do(ctx, request, response)
+}
+
+// DoWithHeaders makes an HTTP request like Do, sending the given headers on top of the ones
+// configured on the client, which they take precedence over, for this call alone.
+func (r *RestClient) DoWithHeaders(ctx context.Context, extraHeaders map[string]string, request interface{}, response interface{}) (int64, error) {
+	result, err := r.doWith(ctx, callOptions{header: extraHeaders}, request, response)
+	return result.Status, err
+}
+
+// DoResult makes an HTTP request like Do, returning the details of the call in a Result.
+// The returned Result is never nil, even when an error is returned. When the request cannot
+// be encoded, or does not match the schema set with WithRequestSchema, nothing is sent and
+// the status is zero.
+func (r *RestClient) DoResult(ctx context.Context, request interface{}, response interface{}) (*Result, error) {
+	return r.doWith(ctx, callOptions{}, request, response)
+}
+
+// callOptions overrides settings of the client for a single call, leaving the client untouched
+// so it can be shared by concurrent calls.
+type callOptions struct {
+	method string
+	url    string
+	header map[string]string // set over the headers of the client
+}
+
+// doWith makes the call with the given options, returning ErrShutdown once the client is shut down.
+func (r *RestClient) doWith(ctx context.Context, opts callOptions, request interface{}, response interface{}) (*Result, error) {
+	ctx, done, err := r.shutdownContext(ctx)
+	if err != nil {
+		return &Result{Status: internalStatusRequestError}, err
+	}
+	defer done()
+
+	result, err := r.doResult(ctx, opts, request, response)
+	// the total timeout is told apart from the deadline of the call context here, past its own context
+	err = shutdownError(ctx, clientTimeout(ctx, err))
+	if err != nil && r.fallback != nil && result.Attempts > 0 && !errors.Is(err, ErrShutdown) {
+		if fallbackErr := setFallback(response, r.fallback); fallbackErr != nil {
+			r.logger().ErrorContext(ctx, "error setting fallback response",
+				"err", fallbackErr,
+			)
+			return result, err
+		}
+		r.resultLogger(result).WarnContext(ctx, "serving fallback response",
+			"err", err,
+			"status", result.Status,
+		)
+		result.Status = StatusFallback
+		result.Fallback = true
+		result.Err = err
+		return result, nil
+	}
+	return result, err
+}
+
+func (r *RestClient) doResult(ctx context.Context, opts callOptions, request interface{}, response interface{}) (*Result, error) {
+
+	result := &Result{}
+	callStarted := r.now()
+
+	ctx, cancel := r.totalContext(ctx)
+	defer cancel()
+
+	// the generated request ID, if any, is shared by all the attempts of the call
+	ctx = r.withRequestID(ctx)
+	result.RequestID = generatedRequestID(ctx)
+
+	// the body is encoded once and sent as is on every attempt
+	body, err := r.encode(request)
+	if err != nil {
+		r.logger().ErrorContext(ctx, "error encoding request",
+			"err", err,
+		)
+		return result, err
+	}
+	if err = r.validateRequest(body); err != nil {
+		r.logger().ErrorContext(ctx, "request does not match the schema",
+			"err", err,
+		)
+		return result, err
+	}
+
+	method := r.method
+	if opts.method != "" {
+		method = opts.method
+	}
+	start := r.startIndex()
+	attemptURL := func(i int64) string {
+		if opts.url != "" {
+			return opts.url
+		}
+		return r.attemptURL(start + i)
+	}
+
+	if r.dryRun {
+		result.Status = StatusDryRun
+		result.Request, err = r.newRequest(ctx, method, attemptURL(0), opts.header, body)
+		return result, err
+	}
+
+	// an io.ReadCloser body is closed once the call is done, after being buffered when small
+	// enough to be sent again on retries
+	defer body.close()
+	if body, err = body.buffer(); err != nil {
+		r.logger().ErrorContext(ctx, "error buffering request body",
+			"err", err,
+		)
+		result.Status = internalStatusRequestError
+		return result, err
+	}
+
+	client := r.httpClient()
+
+	url, err := r.runAttempts(ctx, method, body, result, attemptURL, func(attemptCtx context.Context, i int64, url string) attemptOutcome {
+		status, resp, err := r.call(attemptCtx, r.attemptClient(attemptCtx, client, i), method, url, opts.header, body, result, i)
+		result.Status, result.Body = status, resp
+		if err == nil {
+			resp, err = r.readResponse(ctx, method, url, result, resp, response)
+		}
+		if err == nil {
+			err = mapHeaders(result.Header, response)
+		}
+		return attemptOutcome{body: resp, err: err, response: response}
+	})
+	if err == nil {
+		r.checkLatency(ctx, url, callStarted)
+	}
+	return result, err
+}
+
+// attemptOutcome is the outcome of an attempt, from which runAttempts decides whether to retry it.
+type attemptOutcome struct {
+	body     []byte // the body read, if any, passed to the retry classifier
+	err      error
+	response interface{} // the decoded response, if any, which can ask for a retry by implementing Retryable
+}
+
+// runAttempts makes the attempts of a call, sending each one with send, which sets the status and
+// the headers of the result, and retrying it as decided by retryDecision, up to the maximum number
+// of attempts of the policy of the method. It returns the URL of the last attempt and its error,
+// a *RetryExhaustedError when every attempt was made and the last one would have been retried.
+func (r *RestClient) runAttempts(ctx context.Context, method string, body payload, result *Result, attemptURL func(i int64) string, send func(ctx context.Context, attempt int64, url string) attemptOutcome) (string, error) {
 
 	var (
-		retries int64
-		status  int64
-		err     error
-		resp    []byte
+		retries   int64
+		err       error
+		url       string
+		attempts  []AttemptInfo
+		exhausted bool // every attempt was made and the last one would have been retried
 	)
 
-	client := &http.Client{}
-	if r.timeout > 0 {
-		client.Timeout = r.timeout
+	if r.initialJitter > 0 {
+		if err = r.sleep(ctx, time.Duration(r.random().Int63n(int64(r.initialJitter)+1))); err != nil {
+			r.logger().ErrorContext(ctx, "initial jitter aborted",
+				"err", err,
+			)
+			result.Status = internalStatusRequestError
+			return url, err
+		}
+	}
+
+	if err = r.preflightCheck(ctx); err != nil {
+		result.Status = internalStatusRequestError
+		return url, err
+	}
+
+	policy := r.retryPolicy(method)
+	if policy.MaxAttempts < 1 {
+		err = fmt.Errorf("%w, max attempts is %d", ErrNoAttempts, policy.MaxAttempts)
+		r.logger().ErrorContext(ctx, "no attempts to make",
+			"err", err,
+		)
+		result.Status = internalStatusRequestError
+		return url, err
 	}
 
 	sleep := float64(0)
-	for i := int64(0); i < r.maxAttempts; i++ {
+	var lastReason RetryReason
+	for i := int64(0); i < policy.MaxAttempts; i++ {
+
+		if err = r.sleep(ctx, time.Duration(sleep*float64(time.Second))); err != nil {
+			r.logger().ErrorContext(ctx, "retries aborted",
+				"err", err,
+				"url", url,
+				"attempt", i+1,
+			)
+			result.Status = internalStatusRequestError
+			return url, err
+		}
 
-		time.Sleep(time.Second * time.Duration(sleep))
+		attemptCtx := withAttempt(ctx, i, lastReason)
 
-		status, resp, err = r.call(ctx, *client, request)
+		if i > 0 && r.beforeRetry != nil {
+			if err = r.beforeRetry(attemptCtx, i+1, result.Status); err != nil {
+				r.logger().ErrorContext(ctx, "before retry hook failed",
+					"err", err,
+					"url", url,
+					"attempt", i+1,
+				)
+				result.Status = internalStatusRequestError
+				return url, err
+			}
+		}
+
+		url = attemptURL(i)
+		result.Attempts = i + 1
+		started := r.now()
+		outcome := send(attemptCtx, i, url)
+		err = outcome.err
+		r.observeLatency(result.Status, started)
+		if r.balancer != nil {
+			r.balancer.report(url, result.Status >= http.StatusInternalServerError)
+		}
+
+		decision := r.retryDecision(method, result.Status, outcome.body, err, outcome.response)
+		reason := retryReason(result.Status, result.Header, err)
+		lastReason = reason
+		attempts = append(attempts, AttemptInfo{
+			Status:   result.Status,
+			Err:      err,
+			Duration: r.now().Sub(started),
+			Reason:   reason,
+		})
+		if decision == Abort {
+			r.resultLogger(result).WarnContext(ctx, "retries aborted by the classifier",
+				"err", err,
+				"url", url,
+				"status", result.Status,
+			)
+			if err == nil {
+				err = ErrAborted
+			} else {
+				err = fmt.Errorf("%w: %w", ErrAborted, err)
+			}
+			break
+		}
+
+		// a streamed body can only be sent again when the attempt provably did not send it
+		if !body.replayable(ctx, r.expectContinue) {
+			break
+		}
 
 		// if it is handled error, there is no need to retry
-		if status < http.StatusInternalServerError {
+		if decision == DoNotRetry {
 			break
 		}
 		retries++
+		exhausted = i+1 == policy.MaxAttempts
 
-		slog.WarnContext(ctx, "retrying request",
+		r.resultLogger(result).WarnContext(ctx, "retrying request",
 			"error", err,
-			"url", r.url,
-			"status", status,
+			"url", url,
+			"status", result.Status,
+			"reason", reason.String(),
 			"backoff", sleep,
-			"interval", r.intervalSeconds,
+			"interval", policy.IntervalSeconds,
 			"attempt", retries,
-			"time", time.Now().Format(time.RFC3339),
+			"time", r.now().Format(time.RFC3339),
 		)
+		if r.onRetry != nil && i+1 < policy.MaxAttempts {
+			r.onRetry(ctx, i+2, reason, err)
+		}
 
-		sleep = r.intervalSeconds * (math.Pow(r.backoffRate, float64(i+1)))
+		sleep = r.backoff(policy, i)
+
+	}
 
+	if exhausted && err != nil {
+		err = &RetryExhaustedError{Attempts: attempts}
 	}
 
 	if err != nil {
-		slog.ErrorContext(ctx, "error calling api",
+		r.resultLogger(result).ErrorContext(ctx, "error calling api",
 			"err", err,
-			"url", r.url,
+			"url", url,
+			"status", result.Status,
 		)
-		return internalStatusRequestError, err
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			result.Status = internalStatusRequestError
+		}
+		return url, err
+	}
+
+	r.resultLogger(result).DebugContext(ctx, "request done",
+		"url", url,
+		"retries", retries,
+	)
+
+	return url, nil
+}
+
+// unfollowedRedirect reports whether the response is a redirect without a Location header, which
+// the client could not follow.
+func unfollowedRedirect(result *Result) bool {
+	return result.Status >= http.StatusMultipleChoices && result.Status < http.StatusBadRequest &&
+		result.Status != http.StatusNotModified && result.Header.Get("Location") == ""
+}
+
+// readResponse decrypts, checks, transforms and validates the response body before decoding it
+// into the response target, returning the body as transformed.
+func (r *RestClient) readResponse(ctx context.Context, method string, url string, result *Result, resp []byte, response interface{}) ([]byte, error) {
+
+	// responses other than success ones are not decoded into the response target
+	if !r.success(result.Status) {
+		if unfollowedRedirect(result) {
+			return resp, nil
+		}
+		return resp, r.statusError(ctx, url, result.Status, resp)
+	}
+
+	// the body of HEAD requests and of the statuses without one was not read, so it is not decoded either
+	if r.noBody(method, result.Status) {
+		return resp, r.requireNonEmpty(ctx, url, result, resp)
 	}
 
-	if err = json.Unmarshal(resp, &response); err != nil {
-		slog.ErrorContext(ctx, "failed to Unmarshal data",
+	resp, err := r.decryptBody(result.Header, resp)
+	if err != nil {
+		r.resultLogger(result).ErrorContext(ctx, "error decrypting response body",
 			"err", err,
-			"url", r.url,
+			"url", url,
 		)
-		return internalStatusRequestError, err
 	}
 
-	slog.DebugContext(ctx, "request done",
-		"url", r.url,
-		"retries", retries,
+	if err == nil {
+		err = r.requireNonEmpty(ctx, url, result, resp)
+	}
+
+	if err == nil && r.expectedType != "" {
+		if err = r.checkContentType(result.Header, resp); err != nil {
+			r.resultLogger(result).ErrorContext(ctx, "unexpected content type",
+				"err", err,
+				"url", url,
+			)
+		}
+	}
+
+	if err == nil && r.responseTransform != nil {
+		// the Result keeps the body as received, the transformed one is validated and decoded
+		if resp, err = r.responseTransform(ctx, resp); err != nil {
+			err = fmt.Errorf("transforming response body: %w", err)
+			r.resultLogger(result).ErrorContext(ctx, "error transforming response body",
+				"err", err,
+				"url", url,
+			)
+		}
+	}
+
+	if err == nil && r.responseSchema != nil {
+		if err = r.responseSchema.Validate(resp); err != nil {
+			r.resultLogger(result).ErrorContext(ctx, "response does not match the schema",
+				"err", err,
+				"url", url,
+			)
+		}
+	}
+
+	if err == nil && result.Status < http.StatusInternalServerError && r.validator != nil {
+		if err = r.validator(result.Status, resp); err != nil {
+			r.resultLogger(result).WarnContext(ctx, "invalid response",
+				"err", err,
+				"url", url,
+				"status", result.Status,
+			)
+		}
+	}
+
+	if err == nil {
+		err = r.decodeStatus(ctx, url, result.Status, resp, response)
+	}
+	return resp, err
+}
+
+// requireNonEmpty fails with ErrEmptyBody when the body is empty and WithRequireNonEmptyBody is set.
+func (r *RestClient) requireNonEmpty(ctx context.Context, url string, result *Result, body []byte) error {
+	if !r.requireBody || len(bytes.TrimSpace(body)) > 0 {
+		return nil
+	}
+	err := fmt.Errorf("%w: status %d", ErrEmptyBody, result.Status)
+	r.resultLogger(result).ErrorContext(ctx, "empty response body",
+		"err", err,
+		"url", url,
 	)
+	return err
+}
+
+// decode decodes the response body into the response target. Empty and blank bodies, e.g. of a
+// 200 answering a DELETE or of a redirect that was not followed, are left undecoded, leaving the
+// target as it is, e.g. a nil slice; WithRequireNonEmptyBody turns them into errors instead.
+func (r *RestClient) decode(ctx context.Context, url string, body []byte, response interface{}) error {
+	switch r.responseType {
+	case Raw:
+		target, ok := response.(*[]byte)
+		if !ok {
+			return fmt.Errorf("raw responses require a *[]byte target, got %T", response)
+		}
+		*target = body
+		return nil
+	case Text:
+		target, ok := response.(*string)
+		if !ok {
+			return fmt.Errorf("text responses require a *string target, got %T", response)
+		}
+		*target = string(body)
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	// pointer targets are decoded into directly, so that a null body resets them,
+	// e.g. to a nil slice, instead of only replacing the local interface value
+	target := interface{}(&response)
+	if v := reflect.ValueOf(response); v.Kind() == reflect.Pointer && !v.IsNil() {
+		target = response
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		r.logger().ErrorContext(ctx, "failed to Unmarshal data",
+			"err", err,
+			"url", url,
+		)
+		return err
+	}
+	return nil
+}
+
+// checkContentType reports an error when the Content-Type of the response does not match the expected media type.
+func (r *RestClient) checkContentType(header http.Header, body []byte) error {
+	actual := header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(actual)
+	if r.sniffContentType && (actual == "" || mediaType == "application/octet-stream") {
+		mediaType, err = sniffContentType(body), nil
+	}
+	if err == nil && strings.EqualFold(mediaType, r.expectedType) {
+		return nil
+	}
+	snippet := body
+	if len(snippet) > contentTypeSnippetSize {
+		snippet = snippet[:contentTypeSnippetSize]
+	}
+	return fmt.Errorf("%w: expected %q, got %q: %q", ErrUnexpectedContentType, r.expectedType, actual, snippet)
+}
+
+// sniffContentType guesses the media type of a body lacking a meaningful Content-Type, which is
+// application/json for a body starting with an object or an array and unknown otherwise.
+func sniffContentType(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "application/json"
+	}
+	return ""
+}
+
+// setFallback stores a copy of the fallback into the response target through JSON, so that the
+// caller never shares, and possibly modifies, the value of the client.
+func setFallback(response interface{}, fallback interface{}) error {
+	target := reflect.ValueOf(response)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("response target must be a non-nil pointer, got %T", response)
+	}
+	body, err := json.Marshal(fallback)
+	if err != nil {
+		return err
+	}
+	// whatever a failed attempt decoded into the target is not merged with the fallback
+	target.Elem().Set(reflect.Zero(target.Elem().Type()))
+	return json.Unmarshal(body, response)
+}
+
+// success reports whether the status is one of the success statuses, 2xx by default.
+func (r *RestClient) success(status int64) bool {
+	if len(r.successStatuses) == 0 {
+		return status >= http.StatusOK && status < http.StatusMultipleChoices
+	}
+	for _, code := range r.successStatuses {
+		if int64(code) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultNoBodyStatuses are the statuses whose response body is not read unless set otherwise with WithNoBodyStatuses.
+var defaultNoBodyStatuses = []int{http.StatusNoContent, http.StatusNotModified}
+
+// noBody reports whether the body of the response is left unread: for HEAD requests, and the
+// statuses set with WithNoBodyStatuses.
+func (r *RestClient) noBody(method string, status int64) bool {
+	if method == http.MethodHead {
+		return true
+	}
+	codes := r.noBodyStatuses
+	if codes == nil {
+		codes = defaultNoBodyStatuses
+	}
+	for _, code := range codes {
+		if int64(code) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableStatus reports whether the status is retried: 5xx, and the statuses set with WithRetryOn,
+// unless set with WithNonRetryableStatuses.
+func (r *RestClient) retryableStatus(status int64) bool {
+	if r.nonRetryableStatus(status) {
+		return false
+	}
+	if status >= http.StatusInternalServerError {
+		return true
+	}
+	for _, code := range r.retryOnStatuses {
+		if int64(code) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDecision classifies the outcome of an attempt with the retry classifier when set,
+// retrying retryable statuses, ErrRetryable errors, Retryable responses and the body read errors
+// of idempotent methods, or of every method when set with WithRetryableBodyReadErrors, otherwise.
+// The transport errors are all retried, unless narrowed down with WithRetryableNetErrors.
+// Requests rejected by the circuit breaker, and DNS failures when set with WithFailFastOnDNSError,
+// are never retried.
+func (r *RestClient) retryDecision(method string, status int64, body []byte, err error, response interface{}) RetryDecision {
+	if r.failFast(err) {
+		return DoNotRetry
+	}
+	if r.classifier != nil {
+		return r.classifier(status, body, err)
+	}
+	if errors.Is(err, ErrBodyRead) {
+		if r.retryBodyRead || idempotent(method) {
+			return Retry
+		}
+		return DoNotRetry
+	}
+	if r.permanentNetError(err) {
+		return DoNotRetry
+	}
+	if r.retryableStatus(status) || errors.Is(err, ErrRetryable) || shouldRetry(err, response) {
+		return Retry
+	}
+	return DoNotRetry
+}
+
+// requestIDHeaderName returns the name of the response header carrying the request ID.
+func (r *RestClient) requestIDHeaderName() string {
+	if r.requestIDHeader == "" {
+		return defaultRequestIDHeader
+	}
+	return r.requestIDHeader
+}
+
+// nonRetryableStatus reports whether the status is one of the statuses set with WithNonRetryableStatuses.
+func (r *RestClient) nonRetryableStatus(status int64) bool {
+	for _, code := range r.noRetryStatuses {
+		if int64(code) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRetry reports whether the decoded response asks for the call to be retried.
+func shouldRetry(err error, response interface{}) bool {
+	if err != nil {
+		return false
+	}
+	retryable, ok := response.(Retryable)
+	return ok && retryable.ShouldRetry()
+}
+
+// httpClient returns the HTTP client used to send each attempt.
+func (r *RestClient) httpClient() *http.Client {
+	client := &http.Client{Transport: r.httpTransport()}
+	if r.timeout > 0 {
+		client.Timeout = r.timeout
+	}
+	if r.maxRedirects != nil {
+		client.CheckRedirect = r.checkRedirect
+	}
+	return client
+}
+
+// attemptClient returns a copy of the HTTP client with the timeout of the given attempt, clamped
+// to the time left before the deadline of the context, so that the shorter of the two governs.
+func (r *RestClient) attemptClient(ctx context.Context, client *http.Client, attempt int64) http.Client {
+	attemptClient := *client
+	if r.timeout > 0 {
+		attemptClient.Timeout = r.attemptTimeout(r.timeout, attempt)
+		// a deadline already passed fails the request through the context
+		if deadline, ok := ctx.Deadline(); ok {
+			if left := deadline.Sub(r.now()); left > 0 && left < attemptClient.Timeout {
+				attemptClient.Timeout = left
+			}
+		}
+	}
+	return attemptClient
+}
+
+// totalContext bounds the context of a call by the timeout set with WithTotalTimeout, if any.
+func (r *RestClient) totalContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.totalTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.totalTimeout)
+}
+
+// requestContext bounds the context of the given attempt by the timeout set with WithRequestTimeout,
+// if any, grown and jittered as the attempt requires.
+func (r *RestClient) requestContext(ctx context.Context, attempt int64) (context.Context, context.CancelFunc) {
+	if r.requestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, r.now().Add(r.attemptTimeout(r.requestTimeout, attempt)))
+}
+
+// attemptTimeout returns the timeout of the given attempt, counted from zero, grown and jittered.
+func (r *RestClient) attemptTimeout(timeout time.Duration, attempt int64) time.Duration {
+	return r.jitterTimeout(r.grownTimeout(timeout, attempt))
+}
+
+// grownTimeout grows the timeout for the given attempt, counted from zero, by the timeout growth factor,
+// up to the maximum timeout when set.
+func (r *RestClient) grownTimeout(timeout time.Duration, attempt int64) time.Duration {
+	if r.timeoutGrowth <= 0 || attempt == 0 {
+		return timeout
+	}
+	grown := float64(timeout) * math.Pow(r.timeoutGrowth, float64(attempt))
+	if grown >= math.MaxInt64 {
+		timeout = math.MaxInt64
+	} else {
+		timeout = time.Duration(grown)
+	}
+	if r.maxTimeout > 0 && timeout > r.maxTimeout {
+		return r.maxTimeout
+	}
+	return timeout
+}
+
+// jitterTimeout moves the timeout by a random fraction of it, up to the timeout jitter either way.
+func (r *RestClient) jitterTimeout(timeout time.Duration) time.Duration {
+	if r.timeoutJitter <= 0 {
+		return timeout
+	}
+	jittered := float64(timeout) * (1 + r.timeoutJitter*(2*r.random().Float64()-1))
+	switch {
+	case jittered >= math.MaxInt64:
+		return math.MaxInt64
+	case jittered < 1:
+		// a zero timeout would disable it
+		return 1
+	default:
+		return time.Duration(jittered)
+	}
+}
 
-	return status, err
+// checkRedirect enforces the maximum number of redirects.
+func (r *RestClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	if *r.maxRedirects == 0 {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > *r.maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, *r.maxRedirects)
+	}
+	return nil
 }
 
-func (r *RestClient) call(ctx context.Context, client http.Client, request interface{}) (int64, []byte, error) {
+// backoff returns the number of seconds to wait after the given attempt fails under the policy,
+// between the minimum interval and the maximum backoff, less the jitter.
+func (r *RestClient) backoff(policy RetryPolicy, attempt int64) float64 {
+	policy = r.adapt(policy)
+	var sleep float64
+	switch policy.Strategy {
+	case Constant:
+		sleep = policy.IntervalSeconds
+	case Linear:
+		sleep = policy.IntervalSeconds * policy.BackoffRate * float64(attempt+1)
+	default:
+		sleep = policy.IntervalSeconds * (math.Pow(policy.BackoffRate, float64(attempt+1)))
+	}
+	if policy.MaxBackoff > 0 && sleep > policy.MaxBackoff.Seconds() {
+		sleep = policy.MaxBackoff.Seconds()
+	}
+	if policy.Jitter > 0 {
+		sleep -= sleep * policy.Jitter * r.random().Float64()
+	}
+	if sleep < policy.MinInterval.Seconds() {
+		return policy.MinInterval.Seconds()
+	}
+	return sleep
+}
+
+// logger returns the logger used by the client, tagged with its name and log fields when set.
+func (r *RestClient) logger() *slog.Logger {
+	logger := slog.Default()
+	if r.name != "" {
+		logger = logger.With("client", r.name)
+	}
+	if len(r.logFields) > 0 {
+		keys := make([]string, 0, len(r.logFields))
+		for key := range r.logFields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		args := make([]interface{}, 0, len(keys)*2)
+		for _, key := range keys {
+			args = append(args, key, r.logFields[key])
+		}
+		logger = logger.With(args...)
+	}
+	return logger
+}
+
+// setRequestID sets the request ID the server returned, if any, on the result.
+func (r *RestClient) setRequestID(result *Result, header http.Header) {
+	if id := header.Get(r.requestIDHeaderName()); id != "" {
+		result.RequestID = id
+	}
+}
+
+// resultLogger returns the logger of the client tagged with the request ID of the result, when known.
+func (r *RestClient) resultLogger(result *Result) *slog.Logger {
+	if result.RequestID == "" {
+		return r.logger()
+	}
+	return r.logger().With("request_id", result.RequestID)
+}
+
+// startIndex returns the index of the URL to be used on the first attempt of a call.
+func (r *RestClient) startIndex() int64 {
+	if r.balancer == nil || len(r.urls) == 0 {
+		return 0
+	}
+	return int64(r.balancer.start(len(r.urls), r.random()))
+}
+
+// attemptURL returns the URL at the given index, failing over across the configured
+// URLs when there are more than one and skipping the ones the load balancer considers unhealthy.
+func (r *RestClient) attemptURL(index int64) string {
+	if len(r.urls) == 0 {
+		return r.url
+	}
+	n := int64(len(r.urls))
+	if r.balancer != nil {
+		for i := int64(0); i < n; i++ {
+			url := r.urls[(index+i)%n]
+			if r.balancer.healthy(url) {
+				return url
+			}
+		}
+	}
+	return r.urls[index%n]
+}
+
+// encode encodes the request body with the configured encoder, or with json.Marshal by default.
+// A request that is an io.Reader is streamed as is instead. Encoding errors are wrapped
+// with the type being encoded.
+func (r *RestClient) encode(request interface{}) (payload, error) {
+	if reader, ok := request.(io.Reader); ok {
+		stream := &streamReader{reader: reader}
+		if closer, ok := reader.(io.ReadCloser); ok {
+			stream.closer = closer
+		}
+		return payload{reader: stream, size: readerSize(reader)}, nil
+	}
+	if r.encoder != nil {
+		body, contentType, err := r.encoder(request)
+		if err != nil {
+			return payload{}, fmt.Errorf("encoding request of type %T: %w", request, err)
+		}
+		return payload{body: body, contentType: contentType}, nil
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return payload{}, fmt.Errorf("encoding request of type %T: %w", request, err)
+	}
+	return payload{body: body}, nil
+}
+
+// newRequest builds the HTTP request sent to the given URL, with the encoded body, the configured
+// headers and the ones of the call, which take precedence.
+func (r *RestClient) newRequest(ctx context.Context, method string, url string, header map[string]string, body payload) (*http.Request, error) {
+
+	body, err := r.transformBody(ctx, body)
+	if err != nil {
+		r.logger().ErrorContext(ctx, "error transforming request body",
+			"err", err,
+		)
+		return nil, err
+	}
 
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(request)
+	body, encrypted, err := r.encryptBody(body)
 	if err != nil {
-		slog.ErrorContext(ctx, "error encoding request",
+		r.logger().ErrorContext(ctx, "error encrypting request body",
 			"err", err,
 		)
+		return nil, err
+	}
+
+	if r.methodOverride != "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, r.bodyReader(body))
+	if err != nil {
+		r.logger().ErrorContext(ctx, "error creating request",
+			"err", err,
+		)
+		return nil, err
+	}
+	// bodies of a known size are sent with a Content-Length rather than chunked, which the request
+	// cannot tell by itself once they are wrapped, e.g. to report the upload progress
+	req.ContentLength = body.length()
+	if !body.streamed() {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(r.bodyReader(body)), nil
+		}
+	}
+
+	if r.userAgent != "" {
+		req.Header.Set("User-Agent", r.userAgent)
+	}
+	for key, value := range r.header {
+		req.Header.Set(key, value)
+	}
+	for key, value := range r.rawHeader {
+		req.Header[key] = []string{value}
+	}
+	if body.contentType != "" {
+		req.Header.Set("Content-Type", body.contentType)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	if id := generatedRequestID(ctx); id != "" {
+		req.Header.Set(r.generatedIDHeader, id)
+	}
+	if r.methodOverride != "" {
+		req.Header.Set(methodOverrideHeader, r.methodOverride)
+	}
+	if encrypted {
+		r.setEncryptionHeader(req)
+	}
+	if r.expectContinue && body.streamed() {
+		req.Header.Set("Expect", "100-continue")
+	}
+	r.setDeadline(ctx, req)
+	r.setProxyAuthorization(req)
+
+	if r.requestHook != nil {
+		if err = r.requestHook(req); err != nil {
+			err = fmt.Errorf("request hook: %w", err)
+			r.logger().ErrorContext(ctx, "request hook failed",
+				"err", err,
+			)
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func (r *RestClient) call(ctx context.Context, client http.Client, method string, url string, header map[string]string, body payload, result *Result, attempt int64) (int64, []byte, error) {
+
+	result.Header = nil
+	result.Links = nil
+	result.Trailer = nil
+	result.RequestID = generatedRequestID(ctx)
+	result.FromCache = false
+	result.NotModified = false
+	result.PartialBody = nil
+
+	// the timeouts of the client are told apart from the deadline of the call context
+	callCtx := ctx
+	ctx, cancel := r.requestContext(ctx, attempt)
+	defer cancel()
+
+	req, err := r.newRequest(ctx, method, url, header, body)
+	if err != nil {
 		return internalStatusRequestError, nil, err
 	}
 
-	req, err := http.NewRequest(r.method, r.url, &buf)
+	var (
+		key       string
+		cached    cacheEntry
+		hasCached bool
+	)
+	if r.cache != nil && req.Method == http.MethodGet {
+		key = cacheKey(url, req.Header)
+		cached, hasCached = r.cache.get(key)
+		if hasCached {
+			cached.conditional(req)
+		}
+	}
+
+	release, err := r.acquireSlot(ctx, req.URL.Host)
 	if err != nil {
-		slog.ErrorContext(ctx, "error creating request",
+		r.logger().ErrorContext(ctx, "error waiting for a request slot",
 			"err", err,
 		)
 		return internalStatusRequestError, nil, err
 	}
+	defer release()
 
-	resp, err := client.Do(req)
+	req.Body = body.track(req.Body)
+	resp, err := r.send(&client, req)
+	if err != nil && resp != nil {
+		result.Header = resp.Header
+		r.setRequestID(result, resp.Header)
+		err = responseError(resp, err)
+	}
+	if errors.Is(err, ErrTooManyRedirects) {
+		r.logger().ErrorContext(ctx, "too many redirects",
+			"err", err,
+		)
+		// retrying would follow the same redirects again
+		return int64(resp.StatusCode), nil, err
+	}
 	if err != nil {
-		slog.ErrorContext(ctx, "error making request",
+		err = clientTimeout(callCtx, err)
+		r.logger().ErrorContext(ctx, "error making request",
 			"err", err,
 		)
 		return internalStatusRequestError, nil, err
 	}
 
-	defer resp.Body.Close()
-	bytes, err := io.ReadAll(resp.Body)
+	// the body left unread on errors, e.g. past the maximum response size, is drained
+	defer drainAndClose(resp.Body)
+
+	if err = r.inspectResponse(ctx, resp); err != nil {
+		result.Header = resp.Header
+		return internalStatusRequestError, nil, err
+	}
+	r.trackDownload(resp)
+	var bytes []byte
+	if !r.noBody(method, int64(resp.StatusCode)) {
+		bytes, err = r.readBody(ctx, resp)
+	}
+	if errors.Is(err, ErrResponseTooLarge) {
+		r.logger().ErrorContext(ctx, "response too large",
+			"err", err,
+			"status", resp.StatusCode,
+		)
+		// retrying would get the same response again
+		return int64(resp.StatusCode), nil, err
+	}
 	if err != nil {
-		slog.ErrorContext(ctx, "error reading response",
+		if !timeout(err) && ctx.Err() == nil {
+			err = fmt.Errorf("%w: %w", ErrBodyRead, err)
+		} else {
+			err = clientTimeout(callCtx, err)
+		}
+		r.logger().ErrorContext(ctx, "error reading response",
 			"err", err,
+			"read", len(bytes),
 		)
+		if r.capturePartial {
+			result.PartialBody = bytes
+		}
 		return internalStatusRequestError, nil, err
 	}
 
+	result.Header = resp.Header
+	// relative links are relative to the last request, the one redirects led to
+	base := req.URL
+	if resp.Request != nil {
+		base = resp.Request.URL
+	}
+	result.Links = parseLinks(resp.Header, base)
+	r.setRequestID(result, resp.Header)
+	// trailers are only known once the body has been read
+	result.Trailer = resp.Trailer
+
+	if hasCached && resp.StatusCode == http.StatusNotModified {
+		result.FromCache = true
+		result.NotModified = true
+		return cached.status, cached.body, nil
+	}
+	if r.cache != nil && req.Method == http.MethodGet && resp.StatusCode == http.StatusOK && cacheable(resp.Header) {
+		r.cache.put(key, cacheEntry{
+			status: int64(resp.StatusCode),
+			header: resp.Header,
+			body:   bytes,
+		})
+	}
+
 	return int64(resp.StatusCode), bytes, nil
 }
 
-// NewRestClient creates a new Rest Client
+// wait sleeps for the given duration, returning early with the context error if it is done first.
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// NewRestClient creates a new Rest Client, starting from the settings set with SetDefaults.
 func NewRestClient() *RestClient {
-	return &RestClient{}
+	r := &RestClient{}
+	r.applyDefaults()
+	return r
 }
@@ -1,13 +1,31 @@
 package client
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/mauriciozanettisalomao/go-rest-client/client/clienttest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -126,7 +144,7 @@ func TestDoFailed(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 
 			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				time.Sleep(tc.endpointSleep * time.Second)
+				time.Sleep(tc.endpointSleep)
 				fmt.Fprintf(w, "%v", tc.mockResponse)
 			}))
 			defer svr.Close()
@@ -151,3 +169,3098 @@ func TestDoFailed(t *testing.T) {
 		})
 	}
 }
+
+func TestDoBeforeRetry(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		maxAttempts    int64
+		hookError      error
+		expected       interface{}
+		expectedStatus int64
+		expectedCalls  []int64
+		expectedError  error
+	}{
+		{
+			name:           "switch url on second attempt",
+			maxAttempts:    3,
+			expected:       map[string]interface{}{"message": "success"},
+			expectedStatus: 200,
+			expectedCalls:  []int64{2},
+		},
+		{
+			name:           "hook error aborts retrying",
+			maxAttempts:    3,
+			hookError:      fmt.Errorf("no more endpoints"),
+			expectedStatus: internalStatusRequestError,
+			expectedCalls:  []int64{2},
+			expectedError:  fmt.Errorf("no more endpoints"),
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, `{"message": "unavailable"}`)
+			}))
+			defer failing.Close()
+
+			healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer healthy.Close()
+
+			var calls []int64
+
+			m := &RestClient{}
+			m.WithURL(failing.URL)
+			m.WithMethod("GET")
+			m.WithMaxAttempts(tc.maxAttempts)
+			m.WithBeforeRetry(func(ctx context.Context, attempt int64, prevStatus int64) error {
+				calls = append(calls, attempt)
+				assertion.Equal(int64(http.StatusServiceUnavailable), prevStatus)
+				if tc.hookError != nil {
+					return tc.hookError
+				}
+				m.WithURL(healthy.URL)
+				return nil
+			})
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expectedStatus, status)
+			if err != nil {
+				assertion.Contains(err.Error(), tc.expectedError.Error())
+				return
+			}
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoURLsFailover(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		healthyFirst    bool
+		closeFailing    bool
+		maxAttempts     int64
+		expected        interface{}
+		expectedStatus  int64
+		expectedFailing int
+		expectedHealthy int
+	}{
+		{
+			name:            "failing endpoint first",
+			maxAttempts:     3,
+			expected:        map[string]interface{}{"message": "success"},
+			expectedStatus:  200,
+			expectedFailing: 1,
+			expectedHealthy: 1,
+		},
+		{
+			name:            "dead endpoint first",
+			closeFailing:    true,
+			maxAttempts:     3,
+			expected:        map[string]interface{}{"message": "success"},
+			expectedStatus:  200,
+			expectedFailing: 0,
+			expectedHealthy: 1,
+		},
+		{
+			name:            "healthy endpoint first",
+			healthyFirst:    true,
+			maxAttempts:     3,
+			expected:        map[string]interface{}{"message": "success"},
+			expectedStatus:  200,
+			expectedFailing: 0,
+			expectedHealthy: 1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var failingCalls, healthyCalls int
+
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				failingCalls++
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer failing.Close()
+
+			healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				healthyCalls++
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer healthy.Close()
+
+			if tc.closeFailing {
+				failing.Close()
+			}
+
+			urls := []string{failing.URL, healthy.URL}
+			if tc.healthyFirst {
+				urls = []string{healthy.URL, failing.URL}
+			}
+
+			m := &RestClient{}
+			m.WithURLs(urls...)
+			m.WithMethod("GET")
+			m.WithMaxAttempts(tc.maxAttempts)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedFailing, failingCalls)
+			assertion.Equal(tc.expectedHealthy, healthyCalls)
+		})
+	}
+}
+
+func TestDoResponseValidator(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		mockResponse   string
+		expected       interface{}
+		expectedStatus int64
+		expectedError  error
+	}{
+		{
+			name:           "valid response",
+			mockResponse:   `{"status": "ok"}`,
+			expected:       map[string]interface{}{"status": "ok"},
+			expectedStatus: 200,
+		},
+		{
+			name:           "invalid response",
+			mockResponse:   `{"status": "error"}`,
+			expectedStatus: internalStatusRequestError,
+			expectedError:  fmt.Errorf("unexpected status field: error"),
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.mockResponse)
+			}))
+			defer svr.Close()
+
+			m := &RestClient{}
+			m.WithURL(svr.URL)
+			m.WithMethod("GET")
+			m.WithMaxAttempts(1)
+			m.WithResponseValidator(func(status int64, body []byte) error {
+				var payload struct {
+					Status string `json:"status"`
+				}
+				if err := json.Unmarshal(body, &payload); err != nil {
+					return err
+				}
+				if payload.Status != "ok" {
+					return fmt.Errorf("unexpected status field: %s", payload.Status)
+				}
+				return nil
+			})
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			if err != nil {
+				assertion.Equal(tc.expectedError, err)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoResponseValidatorRetryable(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		maxAttempts    int64
+		bodies         []string
+		expected       interface{}
+		expectedStatus int64
+		expectedCalls  int
+		expectedError  error
+	}{
+		{
+			name:           "pending then done",
+			maxAttempts:    5,
+			bodies:         []string{`{"state": "pending"}`, `{"state": "pending"}`, `{"state": "done"}`},
+			expected:       map[string]interface{}{"state": "done"},
+			expectedStatus: 200,
+			expectedCalls:  3,
+		},
+		{
+			name:           "attempts exhausted",
+			maxAttempts:    2,
+			bodies:         []string{`{"state": "pending"}`, `{"state": "pending"}`, `{"state": "done"}`},
+			expectedStatus: internalStatusRequestError,
+			expectedCalls:  2,
+			expectedError:  ErrRetryable,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.bodies[calls])
+				calls++
+			}))
+			defer svr.Close()
+
+			m := &RestClient{}
+			m.WithURL(svr.URL)
+			m.WithMethod("GET")
+			m.WithMaxAttempts(tc.maxAttempts)
+			m.WithResponseValidator(func(status int64, body []byte) error {
+				var payload struct {
+					State string `json:"state"`
+				}
+				if err := json.Unmarshal(body, &payload); err != nil {
+					return err
+				}
+				if payload.State == "pending" {
+					return fmt.Errorf("still processing: %w", ErrRetryable)
+				}
+				return nil
+			})
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			if err != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoTimeouts(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		maxAttempts     int64
+		intervalSeconds float64
+		timeout         time.Duration
+		totalTimeout    time.Duration
+		slowCalls       int
+		expectedStatus  int64
+		expectedCalls   int
+		expectedError   error
+	}{
+		{
+			name:           "per-attempt timeout is retried",
+			maxAttempts:    3,
+			timeout:        time.Millisecond * 50,
+			slowCalls:      1,
+			expectedStatus: 200,
+			expectedCalls:  2,
+		},
+		{
+			name:            "total timeout aborts the loop",
+			maxAttempts:     5,
+			intervalSeconds: 0.1,
+			totalTimeout:    time.Millisecond * 150,
+			slowCalls:       5,
+			expectedStatus:  internalStatusRequestError,
+			expectedCalls:   1,
+			expectedError:   context.DeadlineExceeded,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(atomic.AddInt32(&calls, 1)) <= tc.slowCalls {
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(time.Millisecond * 100):
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := &RestClient{}
+			m.WithURL(svr.URL)
+			m.WithMethod("GET")
+			m.WithMaxAttempts(tc.maxAttempts)
+			m.WithIntervalSeconds(tc.intervalSeconds)
+			m.WithBackoffRate(1)
+			m.WithTimeout(tc.timeout)
+			m.WithTotalTimeout(tc.totalTimeout)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, int(atomic.LoadInt32(&calls)))
+			if err != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+		})
+	}
+}
+
+func TestDoClientTimeout(t *testing.T) {
+
+	tests := []struct {
+		name                  string
+		timeout               time.Duration
+		requestTimeout        time.Duration
+		totalTimeout          time.Duration
+		contextTimeout        time.Duration
+		statusCode            int
+		expectedClientTimeout bool
+		expectedError         error
+	}{
+		{
+			name:                  "client timeout",
+			timeout:               time.Millisecond * 20,
+			statusCode:            http.StatusOK,
+			expectedClientTimeout: true,
+		},
+		{
+			name:                  "request timeout",
+			requestTimeout:        time.Millisecond * 20,
+			statusCode:            http.StatusOK,
+			expectedClientTimeout: true,
+			expectedError:         context.DeadlineExceeded,
+		},
+		{
+			name:                  "total timeout",
+			totalTimeout:          time.Millisecond * 20,
+			statusCode:            http.StatusOK,
+			expectedClientTimeout: true,
+			expectedError:         context.DeadlineExceeded,
+		},
+		{
+			name:           "context deadline",
+			contextTimeout: time.Millisecond * 20,
+			statusCode:     http.StatusOK,
+			expectedError:  context.DeadlineExceeded,
+		},
+		{
+			name:          "server error",
+			timeout:       time.Second,
+			statusCode:    http.StatusServiceUnavailable,
+			expectedError: &StatusError{},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.statusCode == http.StatusOK {
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(time.Millisecond * 200):
+					}
+				}
+				w.WriteHeader(tc.statusCode)
+				fmt.Fprint(w, `{"message": "done"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithTimeout(tc.timeout).
+				WithRequestTimeout(tc.requestTimeout).
+				WithTotalTimeout(tc.totalTimeout)
+
+			ctx := context.Background()
+			if tc.contextTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.contextTimeout)
+				defer cancel()
+			}
+
+			var result map[string]interface{}
+			_, err := m.Do(ctx, nil, &result)
+			assertion.Error(err)
+			assertion.Equal(tc.expectedClientTimeout, errors.Is(err, ErrClientTimeout))
+			if statusErr, ok := tc.expectedError.(*StatusError); ok {
+				assertion.ErrorAs(err, &statusErr)
+			} else if tc.expectedError != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+			}
+		})
+	}
+}
+
+// captureLogs redirects the default logger to a buffer until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+	})
+	return &buf
+}
+
+// logRecords decodes the JSON log records written to the buffer.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestDoNoAttempts(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	// the maximum number of attempts is left unset
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET")
+
+	var result map[string]interface{}
+	status, err := m.Do(context.Background(), nil, &result)
+	assertion.ErrorIs(err, ErrNoAttempts)
+	assertion.EqualError(err, "no attempts were made, max attempts is 0")
+	assertion.Equal(int64(internalStatusRequestError), status)
+	assertion.Nil(result)
+
+	res, err := m.DoResult(context.Background(), nil, &result)
+	assertion.ErrorIs(err, ErrNoAttempts)
+	assertion.Equal(int64(internalStatusRequestError), res.Status)
+
+	var buf bytes.Buffer
+	status, err = m.DoInto(context.Background(), nil, &buf)
+	assertion.ErrorIs(err, ErrNoAttempts)
+	assertion.Equal(int64(internalStatusRequestError), status)
+	assertion.Empty(buf.String())
+
+	assertion.Equal(int32(0), atomic.LoadInt32(&calls))
+}
+
+func TestDoTimeoutGrowth(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		requestTimeout   time.Duration
+		timeout          time.Duration
+		growth           float64
+		maxTimeout       time.Duration
+		expectedTimeouts []time.Duration
+	}{
+		{
+			name:             "request timeout doubled on each attempt",
+			requestTimeout:   50 * time.Millisecond,
+			growth:           2,
+			expectedTimeouts: []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:             "client timeout grown up to the cap",
+			timeout:          50 * time.Millisecond,
+			growth:           3,
+			maxTimeout:       100 * time.Millisecond,
+			expectedTimeouts: []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond},
+		},
+		{
+			name:             "no growth",
+			requestTimeout:   50 * time.Millisecond,
+			expectedTimeouts: []time.Duration{50 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer svr.Close()
+
+			// the request timeout is the deadline of the attempt, counted from the fake clock
+			clock := clienttest.NewFakeClock(time.Now())
+			var deadlines []time.Duration
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithClock(clock).
+				WithMaxAttempts(int64(len(tc.expectedTimeouts))).
+				WithTimeout(tc.timeout).
+				WithRequestTimeout(tc.requestTimeout).
+				WithTimeoutGrowth(tc.growth).
+				WithMaxTimeout(tc.maxTimeout).
+				WithRequestHook(func(req *http.Request) error {
+					if deadline, ok := req.Context().Deadline(); ok {
+						deadlines = append(deadlines, deadline.Sub(clock.Now()))
+					}
+					return nil
+				})
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.Error(err)
+
+			if tc.requestTimeout > 0 {
+				assertion.Equal(tc.expectedTimeouts, deadlines)
+				return
+			}
+			// the client timeout is the timeout of the HTTP client of the attempt
+			for i, expected := range tc.expectedTimeouts {
+				attemptClient := m.attemptClient(context.Background(), m.httpClient(), int64(i))
+				assertion.Equal(expected, attemptClient.Timeout, "attempt %d", i+1)
+			}
+		})
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		growth     float64
+		maxTimeout time.Duration
+		attempt    int64
+		expected   time.Duration
+	}{
+		{
+			name:     "first attempt",
+			growth:   1.5,
+			attempt:  0,
+			expected: time.Second,
+		},
+		{
+			name:     "third attempt",
+			growth:   1.5,
+			attempt:  2,
+			expected: 2250 * time.Millisecond,
+		},
+		{
+			name:       "capped",
+			growth:     2,
+			maxTimeout: 3 * time.Second,
+			attempt:    5,
+			expected:   3 * time.Second,
+		},
+		{
+			name:     "overflow",
+			growth:   10,
+			attempt:  100,
+			expected: math.MaxInt64,
+		},
+		{
+			name:     "no growth",
+			attempt:  3,
+			expected: time.Second,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			m := NewRestClient().
+				WithTimeoutGrowth(tc.growth).
+				WithMaxTimeout(tc.maxTimeout)
+
+			assertion.Equal(tc.expected, m.attemptTimeout(time.Second, tc.attempt))
+		})
+	}
+}
+
+func TestAttemptTimeoutJitter(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		jitter   float64
+		seed     int64
+		timeout  time.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "jittered with a fixed seed",
+			jitter:   0.2,
+			seed:     42,
+			timeout:  10 * time.Second,
+			expected: time.Duration(float64(10*time.Second) * (1 + 0.2*(2*rand.New(rand.NewSource(42)).Float64()-1))),
+		},
+		{
+			name:     "another seed",
+			jitter:   0.5,
+			seed:     7,
+			timeout:  time.Second,
+			expected: time.Duration(float64(time.Second) * (1 + 0.5*(2*rand.New(rand.NewSource(7)).Float64()-1))),
+		},
+		{
+			name:     "no jitter by default",
+			seed:     42,
+			timeout:  time.Second,
+			expected: time.Second,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			m := NewRestClient().
+				WithSeed(tc.seed).
+				WithTimeout(tc.timeout).
+				WithTimeoutJitter(tc.jitter)
+
+			timeout := m.attemptClient(context.Background(), m.httpClient(), 0).Timeout
+			assertion.Equal(tc.expected, timeout)
+			assertion.GreaterOrEqual(float64(timeout), float64(tc.timeout)*(1-tc.jitter))
+			assertion.LessOrEqual(float64(timeout), float64(tc.timeout)*(1+tc.jitter))
+		})
+	}
+}
+
+func TestDoName(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		clientName      string
+		expectedRecords []string
+	}{
+		{
+			name:            "named client",
+			clientName:      "billing",
+			expectedRecords: []string{"retrying request", "request done"},
+		},
+		{
+			name:            "unnamed client",
+			expectedRecords: []string{"retrying request", "request done"},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			logs := captureLogs(t)
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithName(tc.clientName).
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(2)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+
+			records := logRecords(t, logs)
+			assertion.Len(records, len(tc.expectedRecords))
+			for i, record := range records {
+				assertion.Equal(tc.expectedRecords[i], record["msg"])
+				if tc.clientName == "" {
+					assertion.NotContains(record, "client")
+					continue
+				}
+				assertion.Equal(tc.clientName, record["client"])
+			}
+		})
+	}
+}
+
+func TestDoMethodOverride(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		method           string
+		methodOverride   string
+		expectedMethod   string
+		expectedOverride string
+	}{
+		{
+			name:             "override delete",
+			method:           "DELETE",
+			methodOverride:   "DELETE",
+			expectedMethod:   "POST",
+			expectedOverride: "DELETE",
+		},
+		{
+			name:             "override patch",
+			method:           "GET",
+			methodOverride:   "PATCH",
+			expectedMethod:   "POST",
+			expectedOverride: "PATCH",
+		},
+		{
+			name:             "no override",
+			method:           "PUT",
+			expectedMethod:   "PUT",
+			expectedOverride: "",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var method, override string
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = r.Method
+				override = r.Header.Get("X-HTTP-Method-Override")
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod(tc.method).
+				WithMethodOverride(tc.methodOverride).
+				WithMaxAttempts(1)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedMethod, method)
+			assertion.Equal(tc.expectedOverride, override)
+		})
+	}
+}
+
+func TestDoHeaders(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		methodOverride string
+		expected       map[string]string
+	}{
+		{
+			name:     "client headers",
+			expected: map[string]string{"Authorization": "Bearer token", "X-Tenant": "acme"},
+		},
+		{
+			name:           "client headers along with the method override",
+			methodOverride: "PATCH",
+			expected:       map[string]string{"Authorization": "Bearer token", "X-Tenant": "acme", "X-HTTP-Method-Override": "PATCH"},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var received []http.Header
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = append(received, r.Header.Clone())
+				if len(received) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMethodOverride(tc.methodOverride).
+				WithHeader(map[string]string{"Authorization": "Bearer token", "X-Tenant": "acme"}).
+				WithMaxAttempts(2).
+				WithIntervalSeconds(0)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+
+			// the headers are sent on every attempt
+			assertion.Len(received, 2)
+			for _, header := range received {
+				for key, value := range tc.expected {
+					assertion.Equal(value, header.Get(key))
+				}
+			}
+		})
+	}
+}
+
+func TestDoDryRun(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		method          string
+		headers         map[string]string
+		request         interface{}
+		expectedHeaders map[string]string
+		expectedBody    string
+	}{
+		{
+			name:            "post with body",
+			method:          "POST",
+			headers:         map[string]string{"Content-Type": "application/json", "X-Api-Key": "secret"},
+			request:         map[string]string{"name": "john"},
+			expectedHeaders: map[string]string{"Content-Type": "application/json", "X-Api-Key": "secret"},
+			expectedBody:    `{"name":"john"}`,
+		},
+		{
+			name:            "get without body",
+			method:          "GET",
+			headers:         map[string]string{"Accept": "application/json"},
+			expectedHeaders: map[string]string{"Accept": "application/json"},
+			expectedBody:    "null",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod(tc.method).
+				WithHeader(tc.headers).
+				WithMaxAttempts(1).
+				WithDryRun()
+
+			var result map[string]interface{}
+			res, err := m.DoResult(context.Background(), tc.request, &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(StatusDryRun), res.Status)
+			assertion.Equal(0, calls)
+			assertion.Nil(result)
+
+			assertion.Equal(tc.method, res.Request.Method)
+			assertion.Equal(svr.URL, res.Request.URL.String())
+			for key, value := range tc.expectedHeaders {
+				assertion.Equal(value, res.Request.Header.Get(key))
+			}
+			body, err := io.ReadAll(res.Request.Body)
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		method          string
+		urls            []string
+		headers         map[string]string
+		rawHeaders      map[string]string
+		request         interface{}
+		expectedURL     string
+		expectedHeaders map[string][]string
+		expectedBody    string
+		expectedError   bool
+	}{
+		{
+			name:            "json body and headers",
+			method:          "PUT",
+			urls:            []string{"http://localhost/orders/7"},
+			headers:         map[string]string{"Authorization": "Bearer token"},
+			rawHeaders:      map[string]string{"x-api-key": "secret"},
+			request:         map[string]interface{}{"id": 7, "items": []string{"book"}},
+			expectedURL:     "http://localhost/orders/7",
+			expectedHeaders: map[string][]string{"Authorization": {"Bearer token"}, "x-api-key": {"secret"}},
+			expectedBody:    `{"id":7,"items":["book"]}`,
+		},
+		{
+			name:        "first of several urls",
+			method:      "GET",
+			urls:        []string{"http://primary/orders", "http://secondary/orders"},
+			expectedURL: "http://primary/orders",
+		},
+		{
+			name:          "encoding error",
+			method:        "POST",
+			urls:          []string{"http://localhost/payments"},
+			request:       payment{Amount: -1},
+			expectedError: true,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			m := NewRestClient().
+				WithURLs(tc.urls...).
+				WithMethod(tc.method).
+				WithHeader(tc.headers).
+				WithRawHeaders(tc.rawHeaders)
+
+			req, err := m.BuildRequest(context.Background(), tc.request)
+			if tc.expectedError {
+				assertion.Error(err)
+				assertion.Nil(req)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.method, req.Method)
+			assertion.Equal(tc.expectedURL, req.URL.String())
+			for key, value := range tc.expectedHeaders {
+				assertion.Equal(value, req.Header[key])
+			}
+
+			body, err := io.ReadAll(req.Body)
+			assertion.NoError(err)
+			if tc.expectedBody != "" {
+				expected, err := json.Marshal(tc.request)
+				assertion.NoError(err)
+				assertion.JSONEq(string(expected), string(body))
+				assertion.Equal(tc.expectedBody, string(body))
+				assertion.Equal(int64(len(body)), req.ContentLength)
+			}
+		})
+	}
+}
+
+func TestBuildRequestLoadBalancer(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	urls := []string{"http://primary/orders", "http://secondary/orders", "http://tertiary/orders"}
+	m := NewRestClient().
+		WithURLs(urls...).
+		WithMethod("GET").
+		WithLoadBalancer(RoundRobin).
+		WithDryRun()
+
+	// built requests and dry runs take their turn on the URLs like the calls they stand for
+	for i := 0; i < 2*len(urls); i++ {
+		expected := urls[i%len(urls)]
+		if i%2 == 0 {
+			req, err := m.BuildRequest(context.Background(), nil)
+			assertion.NoError(err)
+			assertion.Equal(expected, req.URL.String())
+			continue
+		}
+		res, err := m.DoResult(context.Background(), nil, nil)
+		assertion.NoError(err)
+		assertion.Equal(expected, res.Request.URL.String())
+	}
+}
+
+func TestDoAsync(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		cancel        bool
+		expected      interface{}
+		expectedError error
+	}{
+		{
+			name:     "completed",
+			expected: map[string]interface{}{"message": "success"},
+		},
+		{
+			name:          "canceled",
+			cancel:        true,
+			expectedError: context.Canceled,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			baseline := runtime.NumGoroutine()
+
+			release := make(chan struct{})
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.cancel {
+					select {
+					case <-r.Context().Done():
+					case <-release:
+					}
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(3)
+
+			var result map[string]interface{}
+			results, cancel := m.DoAsync(context.Background(), nil, &result)
+			if tc.cancel {
+				time.Sleep(50 * time.Millisecond)
+				cancel()
+			}
+
+			select {
+			case res := <-results:
+				if tc.expectedError != nil {
+					assertion.ErrorIs(res.Err, tc.expectedError)
+				} else {
+					assertion.NoError(res.Err)
+					assertion.Equal(int64(200), res.Status)
+					assertion.Equal(tc.expected, result)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("the call did not return")
+			}
+			_, open := <-results
+			assertion.False(open)
+			cancel()
+
+			close(release)
+			svr.Close()
+			m.Close()
+			// polled by hand, as assert.Eventually runs its own goroutines
+			deadline := time.Now().Add(2 * time.Second)
+			for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			assertion.LessOrEqual(runtime.NumGoroutine(), baseline)
+		})
+	}
+}
+
+func TestDoWithTimeout(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Millisecond * 100):
+		}
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithTimeout(time.Second).
+		WithMaxAttempts(1)
+
+	var result map[string]interface{}
+	status, err := m.DoWithTimeout(context.Background(), time.Millisecond*20, nil, &result)
+	assertion.ErrorIs(err, context.DeadlineExceeded)
+	assertion.Equal(int64(internalStatusRequestError), status)
+	assertion.Equal(time.Second, m.timeout)
+
+	status, err = m.Do(context.Background(), nil, &result)
+	assertion.NoError(err)
+	assertion.Equal(int64(200), status)
+	assertion.Equal(map[string]interface{}{"message": "success"}, result)
+}
+
+func TestDoTimeoutClampedToContext(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer svr.Close()
+
+	clock := clienttest.NewFakeClock(time.Now())
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithClock(clock).
+		WithTimeout(time.Second * 10).
+		WithMaxAttempts(1)
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(time.Millisecond*50))
+	defer cancel()
+
+	// the attempt gets the time left before the deadline, not the longer client timeout
+	attemptClient := m.attemptClient(ctx, m.httpClient(), 0)
+	assertion.Equal(time.Millisecond*50, attemptClient.Timeout)
+
+	clock.Advance(time.Millisecond * 20)
+	attemptClient = m.attemptClient(ctx, m.httpClient(), 0)
+	assertion.Equal(time.Millisecond*30, attemptClient.Timeout)
+
+	var result map[string]interface{}
+	status, err := m.Do(ctx, nil, &result)
+	assertion.Error(err)
+	assertion.Equal(int64(internalStatusRequestError), status)
+
+	// without a deadline, the client timeout applies as is
+	attemptClient = m.attemptClient(context.Background(), m.httpClient(), 0)
+	assertion.Equal(time.Second*10, attemptClient.Timeout)
+}
+
+func TestDoWithHeaders(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var received []http.Header
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Clone())
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithHeader(map[string]string{"Authorization": "Bearer client", "X-Tenant": "acme"})
+
+	var result map[string]interface{}
+	status, err := m.DoWithHeaders(context.Background(), map[string]string{"Authorization": "Bearer call", "X-Trace": "1"}, nil, &result)
+	assertion.NoError(err)
+	assertion.Equal(int64(200), status)
+
+	status, err = m.Do(context.Background(), nil, &result)
+	assertion.NoError(err)
+	assertion.Equal(int64(200), status)
+
+	// the headers of the call take precedence over the client ones, for that call alone
+	assertion.Len(received, 2)
+	assertion.Equal("Bearer call", received[0].Get("Authorization"))
+	assertion.Equal("acme", received[0].Get("X-Tenant"))
+	assertion.Equal("1", received[0].Get("X-Trace"))
+	assertion.Equal("Bearer client", received[1].Get("Authorization"))
+	assertion.Equal("acme", received[1].Get("X-Tenant"))
+	assertion.Empty(received[1].Get("X-Trace"))
+}
+
+type jobStatus struct {
+	State string `json:"state"`
+}
+
+func (j *jobStatus) ShouldRetry() bool {
+	return j.State == "running"
+}
+
+func TestDoRetryableResponse(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		maxAttempts   int64
+		bodies        []string
+		expected      jobStatus
+		expectedCalls int
+	}{
+		{
+			name:          "stops when the response no longer asks to retry",
+			maxAttempts:   5,
+			bodies:        []string{`{"state": "running"}`, `{"state": "running"}`, `{"state": "finished"}`, `{"state": "finished"}`},
+			expected:      jobStatus{State: "finished"},
+			expectedCalls: 3,
+		},
+		{
+			name:          "attempts exhausted",
+			maxAttempts:   2,
+			bodies:        []string{`{"state": "running"}`, `{"state": "running"}`, `{"state": "finished"}`},
+			expected:      jobStatus{State: "running"},
+			expectedCalls: 2,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.bodies[calls])
+				calls++
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(tc.maxAttempts)
+
+			var result jobStatus
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoRequestTimeout(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		requestTimeout time.Duration
+		bodyDelay      time.Duration
+		expected       interface{}
+		expectedStatus int64
+		expectedError  error
+	}{
+		{
+			name:           "slow body read is interrupted",
+			requestTimeout: time.Millisecond * 50,
+			bodyDelay:      time.Second * 5,
+			expectedStatus: internalStatusRequestError,
+			expectedError:  context.DeadlineExceeded,
+		},
+		{
+			name:           "body read within the timeout",
+			requestTimeout: time.Second,
+			bodyDelay:      time.Millisecond * 10,
+			expected:       map[string]interface{}{"message": "success"},
+			expectedStatus: 200,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"message": `)
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(tc.bodyDelay):
+				}
+				fmt.Fprint(w, `"success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithRequestTimeout(tc.requestTimeout)
+
+			start := time.Now()
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Less(time.Since(start), time.Second*2)
+			assertion.Equal(tc.expectedStatus, status)
+			if err != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoRedirectWithoutLocation(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		statusCode     int
+		mockResponse   string
+		expectedStatus int64
+		expectedBody   []byte
+	}{
+		{
+			name:           "302 without body",
+			statusCode:     http.StatusFound,
+			expectedStatus: 302,
+			expectedBody:   []byte{},
+		},
+		{
+			name:           "301 with html body",
+			statusCode:     http.StatusMovedPermanently,
+			mockResponse:   "<html>moved</html>",
+			expectedStatus: 301,
+			expectedBody:   []byte("<html>moved</html>"),
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				fmt.Fprint(w, tc.mockResponse)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+
+			var result map[string]interface{}
+			res, err := m.DoResult(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedStatus, res.Status)
+			assertion.Equal(tc.expectedBody, res.Body)
+			assertion.Nil(result)
+		})
+	}
+}
+
+func TestDoEmptyBody(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		statusCode   int
+		mockResponse string
+		expected     map[string]interface{}
+	}{
+		{
+			name:       "200 without body",
+			statusCode: http.StatusOK,
+			expected:   map[string]interface{}{"previous": "value"},
+		},
+		{
+			name:         "201 with a blank body",
+			statusCode:   http.StatusCreated,
+			mockResponse: " \n",
+			expected:     map[string]interface{}{"previous": "value"},
+		},
+		{
+			name:         "200 with a null body",
+			statusCode:   http.StatusOK,
+			mockResponse: "null",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				fmt.Fprint(w, tc.mockResponse)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("DELETE").
+				WithMaxAttempts(1)
+
+			// an empty body has nothing to decode, so the target is left as it is
+			result := map[string]interface{}{"previous": "value"}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(tc.statusCode), status)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoMaxRedirects(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		hops           int
+		maxRedirects   *int
+		expected       interface{}
+		expectedStatus int64
+		expectedCalls  int
+		expectedError  error
+	}{
+		{
+			name:           "chain shorter than the limit",
+			hops:           3,
+			maxRedirects:   intPtr(5),
+			expected:       map[string]interface{}{"message": "success"},
+			expectedStatus: 200,
+			expectedCalls:  4,
+		},
+		{
+			name:           "chain as long as the limit",
+			hops:           3,
+			maxRedirects:   intPtr(3),
+			expected:       map[string]interface{}{"message": "success"},
+			expectedStatus: 200,
+			expectedCalls:  4,
+		},
+		{
+			name:           "chain longer than the limit",
+			hops:           5,
+			maxRedirects:   intPtr(2),
+			expectedStatus: internalStatusRequestError,
+			expectedCalls:  3,
+			expectedError:  ErrTooManyRedirects,
+		},
+		{
+			name:           "redirects disabled",
+			hops:           3,
+			maxRedirects:   intPtr(0),
+			expectedStatus: 302,
+			expectedCalls:  1,
+			expectedError:  &StatusError{Status: 302, Body: []byte("<a href=\"/1\">Found</a>.\n\n")},
+		},
+		{
+			name:           "default limit",
+			hops:           3,
+			expected:       map[string]interface{}{"message": "success"},
+			expectedStatus: 200,
+			expectedCalls:  4,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				var hop int
+				fmt.Sscanf(r.URL.Path, "/%d", &hop)
+				if hop < tc.hops {
+					http.Redirect(w, r, fmt.Sprintf("/%d", hop+1), http.StatusFound)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL + "/0").
+				WithMethod("GET").
+				WithMaxAttempts(3)
+			if tc.maxRedirects != nil {
+				m.WithMaxRedirects(*tc.maxRedirects)
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			if err != nil {
+				var statusErr *StatusError
+				if errors.As(err, &statusErr) {
+					assertion.Equal(tc.expectedError, statusErr)
+					return
+				}
+				assertion.ErrorIs(err, tc.expectedError)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoResponseError(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hop int
+		fmt.Sscanf(r.URL.Path, "/%d", &hop)
+		w.Header().Set("X-Request-Id", fmt.Sprintf("hop-%d", hop))
+		http.Redirect(w, r, fmt.Sprintf("/%d", hop+1), http.StatusFound)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL + "/0").
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithMaxRedirects(2)
+
+	var response map[string]interface{}
+	result, err := m.DoResult(context.Background(), nil, &response)
+	assertion.ErrorIs(err, ErrTooManyRedirects)
+
+	// the response that made the call fail is surfaced, although the call itself did not get a status
+	var responseErr *ResponseError
+	assertion.True(errors.As(err, &responseErr))
+	assertion.Equal(int64(302), responseErr.Status)
+	assertion.Equal("/3", responseErr.Header.Get("Location"))
+	assertion.Equal(int64(internalStatusRequestError), result.Status)
+	assertion.Equal("hop-2", result.RequestID)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+var errInvalidAmount = fmt.Errorf("invalid amount")
+
+type payment struct {
+	Amount int
+}
+
+func (p payment) MarshalJSON() ([]byte, error) {
+	if p.Amount < 0 {
+		return nil, errInvalidAmount
+	}
+	return []byte(fmt.Sprintf(`{"amount": %d}`, p.Amount)), nil
+}
+
+func TestDoEncodeError(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		request        interface{}
+		expectedStatus int64
+		expectedCalls  int
+		expectedError  error
+		expectedType   string
+	}{
+		{
+			name:           "marshaler error",
+			request:        payment{Amount: -1},
+			expectedStatus: 0,
+			expectedCalls:  0,
+			expectedError:  errInvalidAmount,
+			expectedType:   "client.payment",
+		},
+		{
+			name:           "valid request",
+			request:        payment{Amount: 10},
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(3)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), tc.request, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			if err != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				assertion.Contains(err.Error(), tc.expectedType)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+		})
+	}
+}
+
+type order struct {
+	XMLName xml.Name `xml:"order"`
+	ID      int      `xml:"id"`
+}
+
+func TestDoEncoder(t *testing.T) {
+
+	tests := []struct {
+		name                string
+		headers             map[string]string
+		encoder             func(v interface{}) ([]byte, string, error)
+		request             interface{}
+		expectedBody        string
+		expectedContentType string
+	}{
+		{
+			name:    "xml encoder",
+			headers: map[string]string{"Content-Type": "application/json"},
+			encoder: func(v interface{}) ([]byte, string, error) {
+				body, err := xml.Marshal(v)
+				return body, "application/xml", err
+			},
+			request:             order{ID: 7},
+			expectedBody:        `<order><id>7</id></order>`,
+			expectedContentType: "application/xml",
+		},
+		{
+			name: "pretty printed json",
+			encoder: func(v interface{}) ([]byte, string, error) {
+				body, err := json.MarshalIndent(v, "", "  ")
+				return body, "application/json", err
+			},
+			request:             map[string]int{"id": 7},
+			expectedBody:        "{\n  \"id\": 7\n}",
+			expectedContentType: "application/json",
+		},
+		{
+			name:                "default json encoder",
+			headers:             map[string]string{"Content-Type": "application/json"},
+			request:             map[string]int{"id": 7},
+			expectedBody:        "{\"id\":7}",
+			expectedContentType: "application/json",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var body, contentType string
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				body = string(b)
+				contentType = r.Header.Get("Content-Type")
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithHeader(tc.headers).
+				WithMaxAttempts(1).
+				WithEncoder(tc.encoder)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), tc.request, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedBody, body)
+			assertion.Equal(tc.expectedContentType, contentType)
+		})
+	}
+}
+
+func TestDoBodyTransform(t *testing.T) {
+
+	checksum := func(ctx context.Context, body []byte) ([]byte, error) {
+		sum := sha256.Sum256(body)
+		return append([]byte(hex.EncodeToString(sum[:])+"\n"), body...), nil
+	}
+
+	tests := []struct {
+		name          string
+		transform     func(ctx context.Context, body []byte) ([]byte, error)
+		statuses      []int
+		expectedCalls int
+		expectedError bool
+	}{
+		{
+			name:          "checksum prepended on every attempt",
+			transform:     checksum,
+			statuses:      []int{503, 200},
+			expectedCalls: 2,
+		},
+		{
+			name: "transform error",
+			transform: func(ctx context.Context, body []byte) ([]byte, error) {
+				return nil, errors.New("no key")
+			},
+			statuses:      []int{200},
+			expectedCalls: 0,
+			expectedError: true,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			var bodies []string
+			var lengths []int64
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(b))
+				lengths = append(lengths, r.ContentLength)
+				w.WriteHeader(tc.statuses[calls])
+				calls++
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			transforms := 0
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(2).
+				WithBodyTransform(func(ctx context.Context, body []byte) ([]byte, error) {
+					transforms++
+					return tc.transform(ctx, body)
+				})
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), map[string]int{"id": 7}, &result)
+			assertion.Equal(tc.expectedCalls, calls)
+			if tc.expectedError {
+				assertion.Error(err)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedCalls, transforms)
+
+			sum := sha256.Sum256([]byte("{\"id\":7}"))
+			expected := hex.EncodeToString(sum[:]) + "\n" + "{\"id\":7}"
+			for i := range bodies {
+				assertion.Equal(expected, bodies[i])
+				assertion.Equal(int64(len(expected)), lengths[i])
+			}
+		})
+	}
+}
+
+func TestDoResponseTransform(t *testing.T) {
+
+	unwrap := func(ctx context.Context, body []byte) ([]byte, error) {
+		var envelope struct {
+			Data string `json:"data"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(envelope.Data)
+	}
+
+	tests := []struct {
+		name          string
+		body          string
+		expected      interface{}
+		expectedError bool
+	}{
+		{
+			name:     "base64 envelope",
+			body:     `{"data": "` + base64.StdEncoding.EncodeToString([]byte(`{"id": 7, "name": "john"}`)) + `"}`,
+			expected: map[string]interface{}{"id": float64(7), "name": "john"},
+		},
+		{
+			name:          "invalid envelope",
+			body:          `{"data": "not base64!"}`,
+			expectedError: true,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1).
+				WithResponseTransform(unwrap)
+
+			var result map[string]interface{}
+			res, err := m.DoResult(context.Background(), nil, &result)
+			assertion.Equal(tc.body, string(res.Body))
+			if tc.expectedError {
+				assertion.Error(err)
+				assertion.Nil(result)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoFallbackResponse(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		fallback       interface{}
+		statuses       []int
+		expected       []string
+		expectedStatus int64
+		expectedCalls  int
+		expectedFlag   bool
+	}{
+		{
+			name:           "fallback after exhausting retries",
+			fallback:       []string{},
+			statuses:       []int{503, 503, 503},
+			expected:       []string{},
+			expectedStatus: StatusFallback,
+			expectedCalls:  3,
+			expectedFlag:   true,
+		},
+		{
+			name:           "fallback converted through json",
+			fallback:       []interface{}{"cached"},
+			statuses:       []int{503, 503, 503},
+			expected:       []string{"cached"},
+			expectedStatus: StatusFallback,
+			expectedCalls:  3,
+			expectedFlag:   true,
+		},
+		{
+			name:           "no fallback on success",
+			fallback:       []string{},
+			statuses:       []int{503, 200},
+			expected:       []string{"fresh"},
+			expectedStatus: 200,
+			expectedCalls:  2,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[calls])
+				calls++
+				fmt.Fprint(w, `["fresh"]`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(3).
+				WithFallbackResponse(tc.fallback)
+
+			var result []string
+			res, err := m.DoResult(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+			assertion.Equal(tc.expectedStatus, res.Status)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expectedFlag, res.Fallback)
+			assertion.Equal(int64(tc.expectedCalls), res.Attempts)
+			if tc.expectedFlag {
+				var statusErr *StatusError
+				assertion.ErrorAs(res.Err, &statusErr)
+				assertion.Equal(int64(503), statusErr.Status)
+			}
+		})
+	}
+}
+
+func TestDoFallbackResponseNothingSent(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		request  interface{}
+		canceled bool
+		client   func(m *RestClient) *RestClient
+	}{
+		{
+			name:    "request not encoded",
+			request: make(chan int),
+			client:  func(m *RestClient) *RestClient { return m },
+		},
+		{
+			name:    "request not matching the schema",
+			request: map[string]interface{}{"id": 1},
+			client: func(m *RestClient) *RestClient {
+				return m.WithRequestSchema([]byte(`{"type": "object", "required": ["name"]}`))
+			},
+		},
+		{
+			name: "preflight check failed",
+			client: func(m *RestClient) *RestClient {
+				return m.WithPreflight(func(ctx context.Context) error {
+					return errors.New("upstream down")
+				})
+			},
+		},
+		{
+			name:     "initial jitter aborted",
+			canceled: true,
+			client: func(m *RestClient) *RestClient {
+				return m.WithInitialJitter(time.Hour).WithSeed(1)
+			},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer svr.Close()
+
+			m := tc.client(NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(3).
+				WithFallbackResponse([]string{"cached"}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.canceled {
+				cancel()
+			}
+			defer cancel()
+
+			var result []string
+			res, err := m.DoResult(ctx, tc.request, &result)
+			assertion.Error(err)
+			assertion.False(res.Fallback)
+			assertion.Equal(int64(0), res.Attempts)
+			assertion.NotEqual(int64(StatusFallback), res.Status)
+			assertion.Nil(result)
+			assertion.Equal(int32(0), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestDoFallbackResponseCopied(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	fallback := map[string][]string{"items": {"cached"}}
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMaxAttempts(1).
+		WithFallbackResponse(fallback)
+
+	var first map[string][]string
+	status, err := m.Do(context.Background(), nil, &first)
+	assertion.NoError(err)
+	assertion.Equal(int64(StatusFallback), status)
+	first["items"][0] = "modified"
+
+	second := map[string][]string{"stale": {"value"}}
+	status, err = m.Do(context.Background(), nil, &second)
+	assertion.NoError(err)
+	assertion.Equal(int64(StatusFallback), status)
+	assertion.Equal(map[string][]string{"items": {"cached"}}, second)
+	assertion.Equal(map[string][]string{"items": {"cached"}}, fallback)
+}
+
+func TestDoResponseType(t *testing.T) {
+
+	binary := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+
+	tests := []struct {
+		name          string
+		responseType  ResponseType
+		body          []byte
+		response      func() interface{}
+		expected      interface{}
+		expectedError bool
+	}{
+		{
+			name:         "plain text into a string",
+			responseType: Text,
+			body:         []byte("hello, world"),
+			response:     func() interface{} { return new(string) },
+			expected:     "hello, world",
+		},
+		{
+			name:         "binary into bytes",
+			responseType: Raw,
+			body:         binary,
+			response:     func() interface{} { return new([]byte) },
+			expected:     binary,
+		},
+		{
+			name:          "text into a non string target",
+			responseType:  Text,
+			body:          []byte("hello, world"),
+			response:      func() interface{} { return new([]byte) },
+			expectedError: true,
+		},
+		{
+			name:         "json by default",
+			responseType: JSON,
+			body:         []byte(`"hello, world"`),
+			response:     func() interface{} { return new(string) },
+			expected:     "hello, world",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1).
+				WithResponseType(tc.responseType)
+
+			response := tc.response()
+			_, err := m.Do(context.Background(), nil, response)
+			if tc.expectedError {
+				assertion.Error(err)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, reflect.ValueOf(response).Elem().Interface())
+		})
+	}
+}
+
+type item struct {
+	ID int `json:"id"`
+}
+
+func TestDoSliceResponse(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		body     string
+		initial  []item
+		expected []item
+	}{
+		{
+			name:     "json array",
+			body:     `[{"id": 1}, {"id": 2}]`,
+			expected: []item{{ID: 1}, {ID: 2}},
+		},
+		{
+			name:     "empty array",
+			body:     `[]`,
+			expected: []item{},
+		},
+		{
+			name: "null body",
+			body: `null`,
+		},
+		{
+			name:    "null body resets the slice",
+			body:    `null`,
+			initial: []item{{ID: 1}},
+		},
+		{
+			name: "empty body",
+			body: ``,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+
+			response := tc.initial
+			status, err := m.Do(context.Background(), nil, &response)
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(tc.expected, response)
+		})
+	}
+}
+
+func TestDoExpectContentType(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		expected      interface{}
+		expectedCalls int
+		expectedError error
+		expectedInErr string
+	}{
+		{
+			name:          "html error page",
+			contentType:   "text/html; charset=utf-8",
+			body:          "<html><body>Bad Gateway</body></html>",
+			expectedCalls: 1,
+			expectedError: ErrUnexpectedContentType,
+			expectedInErr: "Bad Gateway",
+		},
+		{
+			name:          "missing content type",
+			body:          `{"message": "success"}`,
+			expectedCalls: 1,
+			expectedError: ErrUnexpectedContentType,
+			expectedInErr: "success",
+		},
+		{
+			name:          "matching type with parameters",
+			contentType:   "Application/JSON; charset=utf-8",
+			body:          `{"message": "success"}`,
+			expected:      map[string]interface{}{"message": "success"},
+			expectedCalls: 1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header()["Content-Type"] = []string{tc.contentType}
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(3).
+				WithExpectContentType("application/json")
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedCalls, calls)
+			if tc.expectedError != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				assertion.Contains(err.Error(), tc.expectedInErr)
+				assertion.Nil(result)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoContentTypeSniffing(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		expected      interface{}
+		expectedError error
+	}{
+		{
+			name:     "json object without content type",
+			body:     `{"message": "success"}`,
+			expected: map[string]interface{}{"message": "success"},
+		},
+		{
+			name:        "json array as octet stream",
+			contentType: "application/octet-stream",
+			body:        ` [{"message": "success"}]`,
+			expected:    []interface{}{map[string]interface{}{"message": "success"}},
+		},
+		{
+			name:          "html without content type",
+			body:          "<html><body>Bad Gateway</body></html>",
+			expectedError: ErrUnexpectedContentType,
+		},
+		{
+			name:          "json declared as text",
+			contentType:   "text/plain",
+			body:          `{"message": "success"}`,
+			expectedError: ErrUnexpectedContentType,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = []string{tc.contentType}
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1).
+				WithExpectContentType("application/json").
+				WithContentTypeSniffing()
+
+			var result interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			if tc.expectedError != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoContentLength(t *testing.T) {
+
+	tests := []struct {
+		name                  string
+		request               interface{}
+		uploadProgress        bool
+		expectedContentLength string
+	}{
+		{
+			name:                  "small json body",
+			request:               map[string]string{"a": "b"},
+			expectedContentLength: "9",
+		},
+		{
+			name:                  "larger json body",
+			request:               map[string]string{"name": strings.Repeat("x", 4096)},
+			expectedContentLength: "4107",
+		},
+		{
+			// the body is read through a wrapper whose length the request cannot tell by itself
+			name:                  "json body with upload progress",
+			request:               map[string]string{"a": "b"},
+			uploadProgress:        true,
+			expectedContentLength: "9",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var contentLength string
+			var transferEncoding []string
+			var received int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentLength = r.Header.Get("Content-Length")
+				transferEncoding = r.TransferEncoding
+				b, _ := io.ReadAll(r.Body)
+				received = len(b)
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(1)
+			if tc.uploadProgress {
+				m.WithUploadProgress(func(sent, total int64) {})
+			}
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), tc.request, &result)
+			assertion.NoError(err)
+			assertion.Equal(tc.expectedContentLength, contentLength)
+			assertion.Equal(tc.expectedContentLength, fmt.Sprint(received))
+			assertion.Empty(transferEncoding)
+		})
+	}
+}
+
+func TestDoSuccessStatusCodes(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		successStatuses []int
+		statusCode      int
+		mockResponse    string
+		expected        interface{}
+		expectedStatus  int64
+		expectedError   error
+	}{
+		{
+			name:            "302 configured as success",
+			successStatuses: []int{200, 302},
+			statusCode:      http.StatusFound,
+			mockResponse:    `{"message": "found"}`,
+			expected:        map[string]interface{}{"message": "found"},
+			expectedStatus:  302,
+		},
+		{
+			name:            "200 not configured as success",
+			successStatuses: []int{302},
+			statusCode:      http.StatusOK,
+			mockResponse:    `{"message": "success"}`,
+			expected:        map[string]interface{}(nil),
+			expectedStatus:  200,
+			expectedError:   &StatusError{Status: 200, Body: []byte(`{"message": "success"}`)},
+		},
+		{
+			name:           "default 2xx",
+			statusCode:     http.StatusCreated,
+			mockResponse:   `{"message": "created"}`,
+			expected:       map[string]interface{}{"message": "created"},
+			expectedStatus: 201,
+		},
+		{
+			name:           "default error status",
+			statusCode:     http.StatusNotFound,
+			mockResponse:   `{"error": "not found"}`,
+			expected:       map[string]interface{}(nil),
+			expectedStatus: 404,
+			expectedError:  &StatusError{Status: 404, Body: []byte(`{"error": "not found"}`)},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				fmt.Fprint(w, tc.mockResponse)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithMaxRedirects(0).
+				WithSuccessStatusCodes(tc.successStatuses...)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expected, result)
+			if err != nil {
+				assertion.Equal(tc.expectedError, err)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+		})
+	}
+}
+
+func TestDoNoBodyStatuses(t *testing.T) {
+
+	tests := []struct {
+		name                string
+		method              string
+		noBodyStatuses      []int
+		requireBody         bool
+		statusCode          int
+		expectedStatus      int64
+		expectedDecodes     int
+		expectedStatusError bool
+		expectedError       error
+	}{
+		{
+			name:           "204 not decoded by default",
+			method:         "GET",
+			statusCode:     http.StatusNoContent,
+			expectedStatus: 204,
+		},
+		{
+			name:                "304 not decoded but still failed",
+			method:              "GET",
+			statusCode:          http.StatusNotModified,
+			expectedStatus:      304,
+			expectedStatusError: true,
+		},
+		{
+			name:           "HEAD not decoded",
+			method:         "HEAD",
+			statusCode:     http.StatusOK,
+			expectedStatus: 200,
+		},
+		{
+			name:                "HEAD 404 still failed",
+			method:              "HEAD",
+			statusCode:          http.StatusNotFound,
+			expectedStatus:      404,
+			expectedStatusError: true,
+		},
+		{
+			name:                "HEAD 503 still failed",
+			method:              "HEAD",
+			statusCode:          http.StatusServiceUnavailable,
+			expectedStatus:      503,
+			expectedStatusError: true,
+		},
+		{
+			name:           "configured status",
+			method:         "GET",
+			noBodyStatuses: []int{http.StatusAccepted},
+			statusCode:     http.StatusAccepted,
+			expectedStatus: 202,
+		},
+		{
+			name:                "configured error status still failed",
+			method:              "GET",
+			noBodyStatuses:      []int{http.StatusNotFound},
+			statusCode:          http.StatusNotFound,
+			expectedStatus:      404,
+			expectedStatusError: true,
+		},
+		{
+			name:           "204 with a required body",
+			method:         "GET",
+			requireBody:    true,
+			statusCode:     http.StatusNoContent,
+			expectedStatus: internalStatusRequestError,
+			expectedError:  ErrEmptyBody,
+		},
+		{
+			name:            "200 decoded",
+			method:          "GET",
+			statusCode:      http.StatusOK,
+			expectedStatus:  200,
+			expectedDecodes: 1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			decodes := 0
+			decoder := func(body []byte, target interface{}) error {
+				decodes++
+				return json.Unmarshal(body, target)
+			}
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod(tc.method).
+				WithMaxAttempts(1).
+				WithStatusDecoder(2, decoder, nil).
+				WithStatusDecoder(3, decoder, nil)
+			if tc.noBodyStatuses != nil {
+				m.WithNoBodyStatuses(tc.noBodyStatuses...)
+			}
+			if tc.requireBody {
+				m.WithRequireNonEmptyBody()
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedDecodes, decodes)
+			switch {
+			case tc.expectedStatusError:
+				var statusErr *StatusError
+				if assertion.True(errors.As(err, &statusErr)) {
+					assertion.Equal(tc.expectedStatus, statusErr.Status)
+				}
+			case tc.expectedError != nil:
+				assertion.ErrorIs(err, tc.expectedError)
+			default:
+				assertion.NoError(err)
+			}
+		})
+	}
+}
+
+func TestDoRawHeaders(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		headers       map[string]string
+		rawHeaders    map[string]string
+		expectedLines []string
+	}{
+		{
+			name:          "lower-cased raw header",
+			rawHeaders:    map[string]string{"x-api-key": "secret"},
+			expectedLines: []string{"x-api-key: secret"},
+		},
+		{
+			name:          "mixed-case raw header",
+			rawHeaders:    map[string]string{"X-API-KEY": "secret"},
+			expectedLines: []string{"X-API-KEY: secret"},
+		},
+		{
+			name:          "canonicalized header",
+			headers:       map[string]string{"x-api-key": "secret"},
+			expectedLines: []string{"X-Api-Key: secret"},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// a raw listener is used since the Go server canonicalizes the header names it reads
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			assertion.NoError(err)
+			defer listener.Close()
+
+			lines := make(chan []string, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				var received []string
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					line = strings.TrimRight(line, "\r\n")
+					if err != nil || line == "" {
+						break
+					}
+					received = append(received, line)
+				}
+				lines <- received
+
+				body := `{"message": "success"}`
+				fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(body), body)
+			}()
+
+			m := NewRestClient().
+				WithURL("http://" + listener.Addr().String()).
+				WithMethod("GET").
+				WithHeader(tc.headers).
+				WithRawHeaders(tc.rawHeaders).
+				WithMaxAttempts(1)
+
+			var result map[string]interface{}
+			_, err = m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+
+			received := <-lines
+			for _, line := range tc.expectedLines {
+				assertion.Contains(received, line)
+			}
+		})
+	}
+}
+
+func TestDoRetryOn(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		retryOn        []int
+		statuses       []int
+		expectedStatus int64
+		expectedCalls  int
+	}{
+		{
+			name:           "429 retried when added",
+			retryOn:        []int{429},
+			statuses:       []int{429, 429, 200},
+			expectedStatus: 200,
+			expectedCalls:  3,
+		},
+		{
+			name:           "429 not retried by default",
+			statuses:       []int{429, 200},
+			expectedStatus: 429,
+			expectedCalls:  1,
+		},
+		{
+			name:           "400 never retried",
+			retryOn:        []int{408, 425, 429},
+			statuses:       []int{400, 200},
+			expectedStatus: 400,
+			expectedCalls:  1,
+		},
+		{
+			name:           "5xx still retried",
+			retryOn:        []int{429},
+			statuses:       []int{503, 200},
+			expectedStatus: 200,
+			expectedCalls:  2,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[calls])
+				calls++
+				fmt.Fprint(w, `{"message": "done"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithRetryOn(tc.retryOn...)
+
+			var result map[string]interface{}
+			status, _ := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestDoNonRetryableStatuses(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		nonRetryable   []int
+		retryOn        []int
+		statuses       []int
+		expectedStatus int64
+		expectedCalls  int
+	}{
+		{
+			name:           "501 not retried",
+			nonRetryable:   []int{501},
+			statuses:       []int{501, 200},
+			expectedStatus: 501,
+			expectedCalls:  1,
+		},
+		{
+			name:           "503 still retried",
+			nonRetryable:   []int{501},
+			statuses:       []int{503, 503, 200},
+			expectedStatus: 200,
+			expectedCalls:  3,
+		},
+		{
+			name:           "takes precedence over retry on",
+			nonRetryable:   []int{429},
+			retryOn:        []int{429},
+			statuses:       []int{429, 200},
+			expectedStatus: 429,
+			expectedCalls:  1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[calls])
+				calls++
+				fmt.Fprint(w, `{"message": "done"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithRetryOn(tc.retryOn...).
+				WithNonRetryableStatuses(tc.nonRetryable...)
+
+			var result map[string]interface{}
+			status, _ := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestDoRetryClassifier(t *testing.T) {
+
+	classifier := func(status int64, body []byte, err error) RetryDecision {
+		switch {
+		case bytes.Contains(body, []byte("fatal")):
+			return Abort
+		case bytes.Contains(body, []byte("pending")):
+			return Retry
+		default:
+			return DoNotRetry
+		}
+	}
+
+	tests := []struct {
+		name           string
+		statuses       []int
+		bodies         []string
+		expectedStatus int64
+		expectedCalls  int
+		expectedError  error
+	}{
+		{
+			name:           "retry a success response by its body",
+			statuses:       []int{200, 200, 200},
+			bodies:         []string{`{"state": "pending"}`, `{"state": "pending"}`, `{"state": "done"}`},
+			expectedStatus: 200,
+			expectedCalls:  3,
+		},
+		{
+			name:           "retry until the attempts are exhausted",
+			statuses:       []int{200, 200, 200},
+			bodies:         []string{`{"state": "pending"}`, `{"state": "pending"}`, `{"state": "pending"}`},
+			expectedStatus: 200,
+			expectedCalls:  3,
+		},
+		{
+			name:           "do not retry a 5xx",
+			statuses:       []int{503, 200},
+			bodies:         []string{`{"state": "down"}`, `{"state": "done"}`},
+			expectedStatus: 503,
+			expectedCalls:  1,
+			expectedError:  &StatusError{},
+		},
+		{
+			name:           "abort a success response",
+			statuses:       []int{200, 200},
+			bodies:         []string{`{"state": "fatal"}`, `{"state": "done"}`},
+			expectedStatus: internalStatusRequestError,
+			expectedCalls:  1,
+			expectedError:  ErrAborted,
+		},
+		{
+			name:           "abort an error response keeps its status",
+			statuses:       []int{503, 200},
+			bodies:         []string{`{"state": "fatal"}`, `{"state": "done"}`},
+			expectedStatus: 503,
+			expectedCalls:  1,
+			expectedError:  ErrAborted,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[calls])
+				fmt.Fprint(w, tc.bodies[calls])
+				calls++
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithRetryClassifier(classifier)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			switch expected := tc.expectedError.(type) {
+			case nil:
+				assertion.NoError(err)
+			case *StatusError:
+				assertion.ErrorAs(err, &expected)
+			default:
+				assertion.ErrorIs(err, expected)
+			}
+		})
+	}
+}
+
+func TestDoLogFields(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	logs := captureLogs(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(2).
+		WithLogFields(map[string]interface{}{"tenant": "acme", "operation": "list-invoices"})
+
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	assertion.Error(err)
+
+	records := logRecords(t, logs)
+	var messages []string
+	for _, record := range records {
+		messages = append(messages, record["msg"].(string))
+		assertion.Equal("acme", record["tenant"])
+		assertion.Equal("list-invoices", record["operation"])
+	}
+	assertion.Equal([]string{"retrying request", "retrying request", "error calling api"}, messages)
+}
+
+func TestDoTrailer(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		trailers        map[string]string
+		expectedTrailer http.Header
+	}{
+		{
+			name:            "declared trailers",
+			trailers:        map[string]string{"Grpc-Status": "0", "Grpc-Message": "ok"},
+			expectedTrailer: http.Header{"Grpc-Status": {"0"}, "Grpc-Message": {"ok"}},
+		},
+		{
+			name: "no trailers",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key := range tc.trailers {
+					w.Header().Add("Trailer", key)
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+				for key, value := range tc.trailers {
+					w.Header().Set(key, value)
+				}
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(1)
+
+			var result map[string]interface{}
+			res, err := m.DoResult(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(map[string]interface{}{"message": "success"}, result)
+			if tc.expectedTrailer == nil {
+				assertion.Empty(res.Trailer)
+				return
+			}
+			assertion.Equal(tc.expectedTrailer, res.Trailer)
+		})
+	}
+}
+
+func TestDoRequestID(t *testing.T) {
+
+	tests := []struct {
+		name              string
+		requestIDHeader   string
+		responseHeader    string
+		status            int
+		expectedRequestID string
+		expectedMessage   string
+	}{
+		{
+			name:              "default header on a failed request",
+			responseHeader:    "X-Request-Id",
+			status:            http.StatusServiceUnavailable,
+			expectedRequestID: "req-123",
+			expectedMessage:   "error calling api",
+		},
+		{
+			name:              "custom header on a failed request",
+			requestIDHeader:   "X-Amzn-RequestId",
+			responseHeader:    "X-Amzn-RequestId",
+			status:            http.StatusBadRequest,
+			expectedRequestID: "req-123",
+			expectedMessage:   "error calling api",
+		},
+		{
+			name:              "successful request",
+			responseHeader:    "X-Request-Id",
+			status:            http.StatusOK,
+			expectedRequestID: "req-123",
+			expectedMessage:   "request done",
+		},
+		{
+			name:            "other header ignored",
+			responseHeader:  "X-Correlation-Id",
+			status:          http.StatusServiceUnavailable,
+			expectedMessage: "error calling api",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			buf := captureLogs(t)
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(tc.responseHeader, "req-123")
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, `{"message": "done"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMaxAttempts(2).
+				WithRequestIDHeader(tc.requestIDHeader)
+
+			var result map[string]interface{}
+			res, _ := m.DoResult(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedRequestID, res.RequestID)
+
+			found := false
+			for _, record := range logRecords(t, buf) {
+				if record["msg"] != tc.expectedMessage {
+					continue
+				}
+				found = true
+				if tc.expectedRequestID == "" {
+					assertion.NotContains(record, "request_id")
+					continue
+				}
+				assertion.Equal(tc.expectedRequestID, record["request_id"])
+			}
+			assertion.True(found)
+		})
+	}
+}
+
+func TestDoRequireNonEmptyBody(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		body          string
+		requireBody   bool
+		expectedError error
+	}{
+		{
+			name:          "empty body required",
+			requireBody:   true,
+			expectedError: ErrEmptyBody,
+		},
+		{
+			name:          "blank body required",
+			body:          " \n",
+			requireBody:   true,
+			expectedError: ErrEmptyBody,
+		},
+		{
+			name:        "body present",
+			body:        `{"message": "success"}`,
+			requireBody: true,
+		},
+		{
+			name: "empty body allowed by default",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprint(w, tc.body)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3)
+			if tc.requireBody {
+				m = m.WithRequireNonEmptyBody()
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			// an empty body is not retried, the server would return the same
+			assertion.Equal(1, calls)
+			if tc.expectedError != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				assertion.ErrorContains(err, "status 200")
+				assertion.Equal(int64(internalStatusRequestError), status)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+		})
+	}
+}
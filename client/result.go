@@ -0,0 +1,47 @@
+package client
+
+import "net/http"
+
+const (
+	// StatusDryRun is the status of calls made in dry-run mode, in which nothing is sent.
+	StatusDryRun = 0
+	// StatusFallback is the status of calls that failed and served the response set with
+	// WithFallbackResponse instead. It is never an HTTP status.
+	StatusFallback = -1
+)
+
+// Result holds the details of a call made by the client.
+type Result struct {
+	// Status is the HTTP status of the last attempt.
+	Status int64
+	// Attempts is the number of attempts made, zero when the call failed before sending anything
+	// or was made in dry-run mode.
+	Attempts int64
+	// Header holds the headers of the last response.
+	Header http.Header
+	// RequestID is the correlation ID the server returned for the last response, in the
+	// X-Request-Id header unless another one is set with WithRequestIDHeader, or else the
+	// one generated for the call with WithGeneratedRequestID.
+	RequestID string
+	// Links maps the relation types of the Link headers of the last response to their absolute URLs,
+	// e.g. "next" and "last" for paginated responses.
+	Links map[string]string
+	// Trailer holds the trailers of the last response, sent by the server after the body.
+	Trailer http.Header
+	// Body holds the raw body of the last response.
+	Body []byte
+	// Request is the request built in dry-run mode, which is never sent.
+	Request *http.Request
+	// FromCache tells the body was served from the response cache rather than the network.
+	FromCache bool
+	// NotModified tells the server answered a conditional request with 304 Not Modified.
+	NotModified bool
+	// Fallback tells the call failed and the response set with WithFallbackResponse was served instead.
+	Fallback bool
+	// Err is the error of the call, set on the results of DoAsync and when the
+	// fallback response was served.
+	Err error
+	// PartialBody holds the bytes read before reading the body of the last attempt failed
+	// (e.g. on a timeout), when WithCapturePartialBody is set. It is incomplete by definition.
+	PartialBody []byte
+}
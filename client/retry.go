@@ -0,0 +1,321 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// BackoffStrategy defines how the wait between attempts grows with every retry.
+type BackoffStrategy int
+
+const (
+	// Exponential makes the n-th retry wait IntervalSeconds * BackoffRate^n.
+	Exponential BackoffStrategy = iota
+	// Linear makes the n-th retry wait IntervalSeconds * BackoffRate * n.
+	Linear
+	// Constant makes every retry wait IntervalSeconds, regardless of the backoff rate.
+	Constant
+)
+
+// RetryPolicy holds the settings driving the retries of a call, to be set and validated as a unit.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, the first one included.
+	MaxAttempts int64
+	// IntervalSeconds is the base wait between attempts, in seconds.
+	IntervalSeconds float64
+	// BackoffRate grows the wait on every retry, as set by the strategy.
+	BackoffRate float64
+	// Strategy defines how the wait grows with every retry, exponentially by default.
+	Strategy BackoffStrategy
+	// MaxBackoff caps the wait between attempts, when set.
+	MaxBackoff time.Duration
+	// MinInterval raises the wait between attempts up to it, when set, e.g. when the interval is 0.
+	MinInterval time.Duration
+	// Jitter shortens every wait by a random fraction of it, up to Jitter, between 0 and 1, so that
+	// clients failing at the same time do not retry at the same time.
+	Jitter float64
+}
+
+// Validate returns all the inconsistencies found in the policy, joined in a single error.
+func (p RetryPolicy) Validate() error {
+
+	var errs []error
+
+	if p.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("max attempts must be at least 1, got %d", p.MaxAttempts))
+	}
+	if p.IntervalSeconds < 0 {
+		errs = append(errs, fmt.Errorf("interval seconds must not be negative, got %g", p.IntervalSeconds))
+	}
+	if p.BackoffRate < 0 {
+		errs = append(errs, fmt.Errorf("backoff rate must not be negative, got %g", p.BackoffRate))
+	}
+	// the wait between attempts is the interval times a function of the rate, so a zero rate never waits
+	if p.IntervalSeconds > 0 && p.BackoffRate == 0 && p.Strategy != Constant {
+		errs = append(errs, errors.New("backoff rate is required when interval seconds is set"))
+	}
+	if p.MaxBackoff < 0 {
+		errs = append(errs, fmt.Errorf("max backoff must not be negative, got %s", p.MaxBackoff))
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("jitter must be between 0 and 1, got %g", p.Jitter))
+	}
+	if p.MinInterval < 0 {
+		errs = append(errs, fmt.Errorf("min interval must not be negative, got %s", p.MinInterval))
+	}
+	if p.MaxBackoff > 0 && p.MinInterval > p.MaxBackoff {
+		errs = append(errs, fmt.Errorf("min interval %s must not exceed max backoff %s", p.MinInterval, p.MaxBackoff))
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithRetryPolicy sets all the retry settings at once, replacing the ones set with WithMaxAttempts,
+// WithIntervalSeconds, WithBackoffRate, WithBackoffStrategy, WithMaxBackoff, WithMinInterval and
+// WithRetryJitter. An invalid policy is returned as an error and leaves the client untouched.
+func (r *RestClient) WithRetryPolicy(p RetryPolicy) (*RestClient, error) {
+	if err := p.Validate(); err != nil {
+		return r, fmt.Errorf("invalid retry policy: %w", err)
+	}
+	r.retry = p
+	return r, nil
+}
+
+// WithMethodRetryPolicy sets the retry settings of the calls made with the method, e.g. more
+// attempts for GET than for POST, in place of the ones of the client. An invalid policy is
+// returned as an error and leaves the client untouched.
+func (r *RestClient) WithMethodRetryPolicy(method string, p RetryPolicy) (*RestClient, error) {
+	if err := p.Validate(); err != nil {
+		return r, fmt.Errorf("invalid retry policy for %s: %w", method, err)
+	}
+	if r.methodRetry == nil {
+		r.methodRetry = make(map[string]RetryPolicy)
+	}
+	r.methodRetry[strings.ToUpper(method)] = p
+	return r, nil
+}
+
+// retryPolicy returns the retry settings of the calls made with the method.
+func (r *RestClient) retryPolicy(method string) RetryPolicy {
+	if p, ok := r.methodRetry[strings.ToUpper(method)]; ok {
+		return p
+	}
+	return r.retry
+}
+
+// WithBackoffStrategy sets how the wait between attempts grows with every retry.
+func (r *RestClient) WithBackoffStrategy(strategy BackoffStrategy) *RestClient {
+	r.retry.Strategy = strategy
+	return r
+}
+
+// WithRetryJitter shortens every wait between attempts by a random fraction of it, up to jitter,
+// between 0 and 1. The fractions are drawn from the random number generator of the client, so
+// WithSeed makes the waits reproducible.
+func (r *RestClient) WithRetryJitter(jitter float64) *RestClient {
+	r.retry.Jitter = jitter
+	return r
+}
+
+// WithRetryJitterSeed seeds the jitter of the waits between attempts, making them reproducible.
+// The jitter is drawn from the random number generator of the client, so it is the same as WithSeed.
+func (r *RestClient) WithRetryJitterSeed(seed int64) *RestClient {
+	return r.WithSeed(seed)
+}
+
+// RetryPolicy returns the retry settings of the client.
+func (r *RestClient) RetryPolicy() RetryPolicy {
+	return r.retry
+}
+
+// RetryReason is the cause of a retry, reported to the hook set with WithOnRetry and logged
+// along with each retry.
+type RetryReason int
+
+const (
+	// RetryServerError is a retry after a 5xx status, or another status set with WithRetryOn.
+	RetryServerError RetryReason = iota
+	// RetryTimeout is a retry after the attempt timed out.
+	RetryTimeout
+	// RetryConnectionError is a retry after the request could not be sent or the response could not be read.
+	RetryConnectionError
+	// RetryRateLimited is a retry after a 429 status, or a response carrying a Retry-After header.
+	RetryRateLimited
+	// RetryResponse is a retry asked for by the response itself, through a validator returning
+	// ErrRetryable, a Retryable response or a retry classifier.
+	RetryResponse
+)
+
+func (r RetryReason) String() string {
+	switch r {
+	case RetryServerError:
+		return "server_error"
+	case RetryTimeout:
+		return "timeout"
+	case RetryConnectionError:
+		return "connection_error"
+	case RetryRateLimited:
+		return "rate_limited"
+	case RetryResponse:
+		return "response"
+	default:
+		return fmt.Sprintf("RetryReason(%d)", int(r))
+	}
+}
+
+// WithOnRetry sets a hook that runs whenever an attempt is about to be retried, receiving the
+// number of the attempt to be made, the reason of the retry and the error of the failed attempt.
+func (r *RestClient) WithOnRetry(onRetry func(ctx context.Context, attempt int64, reason RetryReason, err error)) *RestClient {
+	r.onRetry = onRetry
+	return r
+}
+
+// WithFailFastOnDNSError ends the call on the first attempt failing to resolve the host, without
+// retrying, as a host that does not resolve is most likely misconfigured.
+func (r *RestClient) WithFailFastOnDNSError() *RestClient {
+	r.failFastDNS = true
+	return r
+}
+
+// dnsFailure reports whether the error is a DNS resolution failure to fail fast on.
+func (r *RestClient) dnsFailure(err error) bool {
+	var dnsErr *net.DNSError
+	return r.failFastDNS && errors.As(err, &dnsErr)
+}
+
+// failFast reports whether the error ends the call without retrying, whatever the retry settings.
+func (r *RestClient) failFast(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || r.dnsFailure(err)
+}
+
+// ErrBodyRead is wrapped by the errors of attempts whose response body could not be read, e.g.
+// when the connection is reset midway. Timeouts while reading the body are not reported with it.
+var ErrBodyRead = errors.New("reading response body")
+
+// WithRetryableBodyReadErrors retries the calls of non-idempotent methods whose response body
+// could not be read as well, sending the whole request again. Only the calls of idempotent methods
+// are retried otherwise, as the server may have acted upon the request already.
+func (r *RestClient) WithRetryableBodyReadErrors() *RestClient {
+	r.retryBodyRead = true
+	return r
+}
+
+// defaultRetryableNetErrors are the transient transport errors retried under WithRetryableNetErrors
+// when no errors are given.
+var defaultRetryableNetErrors = []error{syscall.ECONNRESET, syscall.EPIPE, io.ErrUnexpectedEOF}
+
+// WithRetryableNetErrors narrows down the transport errors retried, which are otherwise all
+// retried, to timeouts and the errors matching one of errs, ECONNRESET, EPIPE and
+// io.ErrUnexpectedEOF when none are given. Other transport errors, e.g. a refused connection or
+// a failed TLS handshake, are returned right away as they are unlikely to go away on retrying.
+func (r *RestClient) WithRetryableNetErrors(errs ...error) *RestClient {
+	if len(errs) == 0 {
+		errs = defaultRetryableNetErrors
+	}
+	r.netErrors = errs
+	return r
+}
+
+// permanentNetError reports whether the error is a transport error not to be retried, as set
+// with WithRetryableNetErrors.
+func (r *RestClient) permanentNetError(err error) bool {
+	var urlErr *url.Error
+	if r.netErrors == nil || !errors.As(err, &urlErr) || timeout(err) {
+		return false
+	}
+	for _, netErr := range r.netErrors {
+		if errors.Is(err, netErr) {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotent reports whether sending a request with the method more than once has the same
+// effect as sending it once.
+func idempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// timeout reports whether the error is caused by a timeout.
+func timeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// ErrClientTimeout is wrapped by the errors of calls cut short by a timeout of the client, i.e.
+// the ones set with WithTimeout, WithRequestTimeout and WithTotalTimeout, rather than by the
+// deadline of the call context or by the server. This includes the timeouts hit while reading
+// the body copied by DoInto or streamed by DoJSONStream.
+var ErrClientTimeout = errors.New("client timeout")
+
+// clientTimeout wraps the error with ErrClientTimeout when it is a timeout while the context of
+// the call is still alive, meaning one of the timeouts of the client fired.
+func clientTimeout(ctx context.Context, err error) error {
+	if !timeout(err) || ctx.Err() != nil || errors.Is(err, ErrClientTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrClientTimeout, err)
+}
+
+// retryReason classifies the cause of retrying an attempt from its status, response headers and error.
+func retryReason(status int64, header http.Header, err error) RetryReason {
+	switch {
+	case timeout(err):
+		return RetryTimeout
+	case status == internalStatusRequestError:
+		return RetryConnectionError
+	case status == http.StatusTooManyRequests || header.Get("Retry-After") != "":
+		return RetryRateLimited
+	case status >= http.StatusInternalServerError:
+		return RetryServerError
+	}
+	// other statuses are only retried when set with WithRetryOn
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return RetryServerError
+	}
+	return RetryResponse
+}
+
+// AttemptInfo describes an attempt of a call.
+type AttemptInfo struct {
+	// Status is the status of the attempt, or the internal request error status when it got no response.
+	Status int64
+	// Err is the error the attempt failed with.
+	Err error
+	// Duration is the time the attempt took, from sending the request to handling the response.
+	Duration time.Duration
+	// Reason is the cause the attempt was retried for.
+	Reason RetryReason
+}
+
+// RetryExhaustedError is returned when every attempt of a call failed, listing them in order.
+// It unwraps to the error of the last attempt.
+type RetryExhaustedError struct {
+	Attempts []AttemptInfo
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("retries exhausted after %d attempts: %v", len(e.Attempts), e.Unwrap())
+}
+
+func (e *RetryExhaustedError) Unwrap() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
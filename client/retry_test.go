@@ -0,0 +1,667 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRetryPolicy(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		policy        RetryPolicy
+		expectedError []string
+	}{
+		{
+			name:   "single attempt",
+			policy: RetryPolicy{MaxAttempts: 1},
+		},
+		{
+			name:   "capped exponential backoff",
+			policy: RetryPolicy{MaxAttempts: 5, IntervalSeconds: 0.5, BackoffRate: 2, MaxBackoff: 3 * time.Second},
+		},
+		{
+			name:   "constant backoff without backoff rate",
+			policy: RetryPolicy{MaxAttempts: 3, IntervalSeconds: 1, Strategy: Constant},
+		},
+		{
+			name:          "no attempts",
+			policy:        RetryPolicy{},
+			expectedError: []string{"max attempts must be at least 1"},
+		},
+		{
+			name:          "negative interval",
+			policy:        RetryPolicy{MaxAttempts: 3, IntervalSeconds: -1, BackoffRate: 1},
+			expectedError: []string{"interval seconds must not be negative"},
+		},
+		{
+			name:          "interval without backoff rate",
+			policy:        RetryPolicy{MaxAttempts: 3, IntervalSeconds: 1},
+			expectedError: []string{"backoff rate is required"},
+		},
+		{
+			name:          "min interval above max backoff",
+			policy:        RetryPolicy{MaxAttempts: 3, MinInterval: 2 * time.Second, MaxBackoff: time.Second},
+			expectedError: []string{"min interval 2s must not exceed max backoff 1s"},
+		},
+		{
+			name:          "jitter above 1",
+			policy:        RetryPolicy{MaxAttempts: 3, Jitter: 1.5},
+			expectedError: []string{"jitter must be between 0 and 1"},
+		},
+		{
+			name:          "several problems",
+			policy:        RetryPolicy{MaxAttempts: -1, BackoffRate: -2, MaxBackoff: -time.Second},
+			expectedError: []string{"max attempts must be at least 1", "backoff rate must not be negative", "max backoff must not be negative"},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			previous := RetryPolicy{MaxAttempts: 2, IntervalSeconds: 1, BackoffRate: 1}
+			m := NewRestClient().
+				WithMaxAttempts(previous.MaxAttempts).
+				WithIntervalSeconds(previous.IntervalSeconds).
+				WithBackoffRate(previous.BackoffRate)
+
+			got, err := m.WithRetryPolicy(tc.policy)
+			assertion.Same(m, got)
+			if len(tc.expectedError) > 0 {
+				assertion.Error(err)
+				for _, expected := range tc.expectedError {
+					assertion.Contains(err.Error(), expected)
+				}
+				assertion.Equal(previous, m.RetryPolicy())
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.policy, m.RetryPolicy())
+		})
+	}
+}
+
+func TestDoRetryPolicy(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		policy         RetryPolicy
+		expectedSleeps []time.Duration
+	}{
+		{
+			name:           "exponential backoff",
+			policy:         RetryPolicy{MaxAttempts: 4, IntervalSeconds: 1, BackoffRate: 2},
+			expectedSleeps: []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:           "capped backoff",
+			policy:         RetryPolicy{MaxAttempts: 5, IntervalSeconds: 1, BackoffRate: 2, MaxBackoff: 5 * time.Second},
+			expectedSleeps: []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:           "linear backoff",
+			policy:         RetryPolicy{MaxAttempts: 4, IntervalSeconds: 1, BackoffRate: 2, Strategy: Linear},
+			expectedSleeps: []time.Duration{2 * time.Second, 4 * time.Second, 6 * time.Second},
+		},
+		{
+			name:           "capped linear backoff",
+			policy:         RetryPolicy{MaxAttempts: 5, IntervalSeconds: 1, BackoffRate: 1.5, Strategy: Linear, MaxBackoff: 4 * time.Second},
+			expectedSleeps: []time.Duration{1500 * time.Millisecond, 3 * time.Second, 4 * time.Second, 4 * time.Second},
+		},
+		{
+			name:           "min interval without interval",
+			policy:         RetryPolicy{MaxAttempts: 3, MinInterval: 500 * time.Millisecond},
+			expectedSleeps: []time.Duration{500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name:           "min interval below the computed backoff",
+			policy:         RetryPolicy{MaxAttempts: 4, IntervalSeconds: 0.25, BackoffRate: 2, MinInterval: time.Second},
+			expectedSleeps: []time.Duration{time.Second, time.Second, 2 * time.Second},
+		},
+		{
+			name:           "constant backoff",
+			policy:         RetryPolicy{MaxAttempts: 4, IntervalSeconds: 1.5, Strategy: Constant},
+			expectedSleeps: []time.Duration{1500 * time.Millisecond, 1500 * time.Millisecond, 1500 * time.Millisecond},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer svr.Close()
+
+			clock := &recordingClock{}
+			m, err := NewRestClient().
+				WithURL(svr.URL).
+				WithClock(clock).
+				WithRetryPolicy(tc.policy)
+			assertion.NoError(err)
+
+			var result map[string]interface{}
+			_, err = m.Do(context.Background(), nil, &result)
+			assertion.Error(err)
+			assertion.Equal(int(tc.policy.MaxAttempts), calls)
+			assertion.Equal(tc.expectedSleeps, clock.sleeps)
+		})
+	}
+}
+
+func TestRetrySettersDelegateToPolicy(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	m := NewRestClient().
+		WithMaxAttempts(3).
+		WithIntervalSeconds(0.25).
+		WithBackoffRate(1.5).
+		WithBackoffStrategy(Linear).
+		WithMaxBackoff(time.Second).
+		WithMinInterval(100 * time.Millisecond)
+
+	assertion.Equal(RetryPolicy{MaxAttempts: 3, IntervalSeconds: 0.25, BackoffRate: 1.5, Strategy: Linear, MaxBackoff: time.Second, MinInterval: 100 * time.Millisecond}, m.RetryPolicy())
+	assertion.NoError(m.RetryPolicy().Validate())
+}
+
+func TestDoRetryReason(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		handler        func(w http.ResponseWriter)
+		timeout        time.Duration
+		retryOn        []int
+		validator      func(status int64, body []byte) error
+		closed         bool
+		expectedReason RetryReason
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectedReason: RetryServerError,
+		},
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter) {
+				time.Sleep(time.Millisecond * 200)
+			},
+			timeout:        time.Millisecond * 50,
+			expectedReason: RetryTimeout,
+		},
+		{
+			name:           "connection error",
+			closed:         true,
+			expectedReason: RetryConnectionError,
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			retryOn:        []int{http.StatusTooManyRequests},
+			expectedReason: RetryRateLimited,
+		},
+		{
+			name: "retryable response",
+			handler: func(w http.ResponseWriter) {
+				fmt.Fprint(w, `{"status": "pending"}`)
+			},
+			validator: func(status int64, body []byte) error {
+				return ErrRetryable
+			},
+			expectedReason: RetryResponse,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.handler(w)
+			}))
+			defer svr.Close()
+			if tc.closed {
+				svr.Close()
+			}
+
+			var reasons []RetryReason
+			var attempts []int64
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(2).
+				WithClock(&recordingClock{}).
+				WithTimeout(tc.timeout).
+				WithRetryOn(tc.retryOn...).
+				WithResponseValidator(tc.validator).
+				WithOnRetry(func(ctx context.Context, attempt int64, reason RetryReason, err error) {
+					assertion.Error(err)
+					attempts = append(attempts, attempt)
+					reasons = append(reasons, reason)
+				})
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.Error(err)
+			assertion.Equal([]int64{2}, attempts)
+			assertion.Equal([]RetryReason{tc.expectedReason}, reasons)
+		})
+	}
+}
+
+func TestDoRetryExhausted(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	calls := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(3).
+		WithRetryOn(http.StatusTooManyRequests).
+		WithClock(&recordingClock{})
+
+	var result map[string]interface{}
+	status, err := m.Do(context.Background(), nil, &result)
+	assertion.Equal(int64(503), status)
+
+	var exhaustedErr *RetryExhaustedError
+	assertion.True(errors.As(err, &exhaustedErr))
+	assertion.Len(exhaustedErr.Attempts, 3)
+	assertion.Equal([]int64{503, 429, 503}, []int64{exhaustedErr.Attempts[0].Status, exhaustedErr.Attempts[1].Status, exhaustedErr.Attempts[2].Status})
+	assertion.Equal([]RetryReason{RetryServerError, RetryRateLimited, RetryServerError}, []RetryReason{exhaustedErr.Attempts[0].Reason, exhaustedErr.Attempts[1].Reason, exhaustedErr.Attempts[2].Reason})
+	for _, attempt := range exhaustedErr.Attempts {
+		var statusErr *StatusError
+		assertion.True(errors.As(attempt.Err, &statusErr))
+		assertion.Equal(attempt.Status, statusErr.Status)
+	}
+
+	// the error of the last attempt is still reachable
+	var statusErr *StatusError
+	assertion.True(errors.As(err, &statusErr))
+	assertion.Equal(int64(503), statusErr.Status)
+	assertion.Contains(err.Error(), "retries exhausted after 3 attempts")
+}
+
+func TestDoNotRetriedNotExhausted(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(3)
+
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	var exhaustedErr *RetryExhaustedError
+	assertion.False(errors.As(err, &exhaustedErr))
+	assertion.Equal(&StatusError{Status: 404, Body: []byte{}}, err)
+}
+
+func TestDoRetryJitterSeed(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	sleeps := func(seed int64) []time.Duration {
+		clock := &recordingClock{}
+		m := NewRestClient().
+			WithURL(svr.URL).
+			WithMethod("GET").
+			WithMaxAttempts(5).
+			WithIntervalSeconds(1).
+			WithBackoffRate(2).
+			WithRetryJitter(0.5).
+			WithRetryJitterSeed(seed).
+			WithClock(clock)
+
+		var result map[string]interface{}
+		_, err := m.Do(context.Background(), nil, &result)
+		assertion.Error(err)
+		return clock.sleeps
+	}
+
+	first, second := sleeps(42), sleeps(42)
+	assertion.Equal(first, second)
+	assertion.NotEqual(first, sleeps(7))
+
+	// every wait is shortened by up to half of the exponential backoff
+	assertion.Len(first, 4)
+	for i, sleep := range first {
+		backoff := time.Duration(1<<(i+1)) * time.Second
+		assertion.LessOrEqual(sleep, backoff)
+		assertion.GreaterOrEqual(sleep, backoff/2)
+	}
+}
+
+func TestDoFailFastOnDNSError(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		failFast         bool
+		expectedAttempts int
+	}{
+		{
+			name:             "dns error not retried",
+			failFast:         true,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "dns error retried by default",
+			expectedAttempts: 3,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			attempts := 1
+			m := NewRestClient().
+				WithURL("http://orders.example.com").
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithIntervalSeconds(0).
+				WithOnRetry(func(ctx context.Context, attempt int64, reason RetryReason, err error) {
+					attempts++
+				})
+			if tc.failFast {
+				m = m.WithFailFastOnDNSError()
+			}
+			// the host is never resolved, so the test does not depend on the resolver of the machine
+			m.httpTransport().DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, _, _ := net.SplitHostPort(addr)
+				return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}}
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			var dnsErr *net.DNSError
+			assertion.ErrorAs(err, &dnsErr)
+			assertion.Equal(int64(internalStatusRequestError), status)
+			assertion.Equal(tc.expectedAttempts, attempts)
+			if tc.failFast {
+				var exhausted *RetryExhaustedError
+				assertion.False(errors.As(err, &exhausted))
+			}
+		})
+	}
+}
+
+func TestWithMethodRetryPolicy(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		method        string
+		expectedCalls int
+	}{
+		{
+			name:          "get uses its own policy",
+			method:        http.MethodGet,
+			expectedCalls: 5,
+		},
+		{
+			name:          "post uses its own policy",
+			method:        http.MethodPost,
+			expectedCalls: 2,
+		},
+		{
+			name:          "other methods fall back to the client policy",
+			method:        http.MethodPut,
+			expectedCalls: 3,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod(tc.method).
+				WithMaxAttempts(3)
+			m, err := m.WithMethodRetryPolicy(http.MethodGet, RetryPolicy{MaxAttempts: 5})
+			assertion.NoError(err)
+			m, err = m.WithMethodRetryPolicy("post", RetryPolicy{MaxAttempts: 2})
+			assertion.NoError(err)
+
+			var result map[string]interface{}
+			_, err = m.Do(context.Background(), nil, &result)
+			var exhausted *RetryExhaustedError
+			if assertion.ErrorAs(err, &exhausted) {
+				assertion.Len(exhausted.Attempts, tc.expectedCalls)
+			}
+			assertion.Equal(tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestWithMethodRetryPolicyInvalid(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	m := NewRestClient()
+	_, err := m.WithMethodRetryPolicy(http.MethodGet, RetryPolicy{MaxAttempts: 0})
+	assertion.ErrorContains(err, "invalid retry policy for GET")
+	assertion.Empty(m.methodRetry)
+}
+
+func TestDoRetryableBodyReadErrors(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		method        string
+		retryable     bool
+		expectedCalls int
+		expectedError error
+	}{
+		{
+			name:          "idempotent request retried by default",
+			method:        http.MethodGet,
+			expectedCalls: 2,
+		},
+		{
+			name:          "non idempotent request not retried by default",
+			method:        http.MethodPost,
+			expectedCalls: 1,
+			expectedError: ErrBodyRead,
+		},
+		{
+			name:          "non idempotent request retried",
+			method:        http.MethodPost,
+			retryable:     true,
+			expectedCalls: 2,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls > 1 {
+					fmt.Fprint(w, `{"message": "success"}`)
+					return
+				}
+				// the first response announces more than it sends before the connection is closed
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"message\": ")
+				buf.Flush()
+				conn.Close()
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod(tc.method).
+				WithMaxAttempts(3)
+			if tc.retryable {
+				m = m.WithRetryableBodyReadErrors()
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedCalls, calls)
+			if tc.expectedError != nil {
+				assertion.ErrorIs(err, tc.expectedError)
+				assertion.Equal(int64(internalStatusRequestError), status)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(map[string]interface{}{"message": "success"}, result)
+		})
+	}
+}
+
+func TestDoRetryableNetErrors(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		netErrors     []error
+		narrowed      bool
+		expectedCalls int
+	}{
+		{
+			name:          "connection reset retried by default",
+			expectedCalls: 2,
+		},
+		{
+			name:          "connection reset retried with the default net errors",
+			narrowed:      true,
+			expectedCalls: 2,
+		},
+		{
+			name:          "connection reset not retried when not listed",
+			narrowed:      true,
+			netErrors:     []error{syscall.EPIPE},
+			expectedCalls: 1,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var calls int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) > 1 {
+					fmt.Fprint(w, `{"message": "success"}`)
+					return
+				}
+				// the first request is answered with a connection reset
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				conn.(*net.TCPConn).SetLinger(0)
+				conn.Close()
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3)
+			if tc.narrowed {
+				m = m.WithRetryableNetErrors(tc.netErrors...)
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedCalls, int(atomic.LoadInt32(&calls)))
+			if tc.expectedCalls == 1 {
+				assertion.ErrorIs(err, syscall.ECONNRESET)
+				assertion.Equal(int64(internalStatusRequestError), status)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+		})
+	}
+}
+
+func TestDoRetryableNetErrorsPermanent(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	// nothing listens on the address of a closed server
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	svr.Close()
+
+	attempts := 1
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(3).
+		WithRetryableNetErrors().
+		WithOnRetry(func(ctx context.Context, attempt int64, reason RetryReason, err error) {
+			attempts++
+		})
+
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	assertion.ErrorIs(err, syscall.ECONNREFUSED)
+	assertion.Equal(1, attempts)
+}
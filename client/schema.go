@@ -0,0 +1,356 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrSchemaViolation is wrapped by the errors of bodies not conforming to their JSON Schema.
+var ErrSchemaViolation = errors.New("schema violation")
+
+// SchemaError lists the violations of a body validated against a JSON Schema.
+type SchemaError struct {
+	Violations []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrSchemaViolation, strings.Join(e.Violations, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// SchemaValidator validates JSON documents against a compiled JSON Schema.
+type SchemaValidator interface {
+	Validate(document []byte) error
+}
+
+// SchemaCompiler compiles a JSON Schema into a validator. The built-in one supports the type, enum,
+// const, properties, required, additionalProperties (as a boolean), items (as a schema), minItems,
+// maxItems, minLength, maxLength, minimum and maximum keywords, along with the annotations, and
+// fails with ErrUnsupportedSchema on any other keyword rather than ignoring it; a complete JSON
+// Schema library can be plugged in with WithSchemaCompiler.
+type SchemaCompiler func(schema []byte) (SchemaValidator, error)
+
+// ErrUnsupportedSchema is returned by the built-in SchemaCompiler for schemas using keywords it does not implement.
+var ErrUnsupportedSchema = errors.New("unsupported schema")
+
+// WithSchemaCompiler sets the compiler of the schemas of the client, e.g. to use a complete JSON
+// Schema library. It must be set before the schemas.
+func (r *RestClient) WithSchemaCompiler(compiler SchemaCompiler) *RestClient {
+	r.schemaCompiler = compiler
+	return r
+}
+
+// WithResponseSchema validates the body of success responses against the JSON Schema before it is
+// decoded, failing the call with a *SchemaError listing the violations, which is not retried.
+// A schema that does not compile fails every call.
+func (r *RestClient) WithResponseSchema(schema []byte) *RestClient {
+	r.responseSchema = r.compileSchema(schema)
+	return r
+}
+
+// WithRequestSchema validates encoded request bodies against the JSON Schema before anything is
+// sent, failing the call with a *SchemaError listing the violations. Bodies streamed from an
+// io.Reader are not validated. A schema that does not compile fails every call.
+func (r *RestClient) WithRequestSchema(schema []byte) *RestClient {
+	r.requestSchema = r.compileSchema(schema)
+	return r
+}
+
+// validateRequest validates the encoded request body against the request schema, when set.
+func (r *RestClient) validateRequest(body payload) error {
+	if r.requestSchema == nil || body.streamed() {
+		return nil
+	}
+	return r.requestSchema.Validate(body.body)
+}
+
+// compileSchema compiles the schema with the compiler of the client, returning a validator
+// failing with the compilation error when the schema is invalid.
+func (r *RestClient) compileSchema(schema []byte) SchemaValidator {
+	compiler := r.schemaCompiler
+	if compiler == nil {
+		compiler = compileSchema
+	}
+	validator, err := compiler(schema)
+	if err != nil {
+		return invalidSchema{err: fmt.Errorf("compiling schema: %w", err)}
+	}
+	return validator
+}
+
+// invalidSchema fails every validation with the error the schema failed to compile with.
+type invalidSchema struct {
+	err error
+}
+
+func (s invalidSchema) Validate([]byte) error {
+	return s.err
+}
+
+// jsonSchema is the built-in SchemaValidator.
+type jsonSchema struct {
+	root map[string]interface{}
+}
+
+func compileSchema(schema []byte) (SchemaValidator, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, err
+	}
+	if err := checkSchema(root, "$"); err != nil {
+		return nil, err
+	}
+	return jsonSchema{root: root}, nil
+}
+
+// annotationKeywords are the keywords documenting a schema, which do not take part in the validation.
+var annotationKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// checkSchema fails with ErrUnsupportedSchema when the schema at the path, or any of its
+// subschemas, uses a keyword, or a form of a keyword, that validateSchema does not implement,
+// so that a schema is never accepted only to let any document through.
+func checkSchema(schema map[string]interface{}, path string) error {
+	unsupported := func(keyword string) error {
+		return fmt.Errorf("%w: keyword %q at %s", ErrUnsupportedSchema, keyword, path)
+	}
+	keywords := make([]string, 0, len(schema))
+	for keyword := range schema {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		value := schema[keyword]
+		switch keyword {
+		case "type", "enum", "const", "required", "minItems", "maxItems", "minLength", "maxLength", "minimum", "maximum":
+		case "additionalProperties":
+			if _, ok := value.(bool); !ok {
+				return unsupported(keyword)
+			}
+		case "items":
+			items, ok := value.(map[string]interface{})
+			if !ok {
+				return unsupported(keyword)
+			}
+			if err := checkSchema(items, path+"[]"); err != nil {
+				return err
+			}
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return unsupported(keyword)
+			}
+			names := make([]string, 0, len(properties))
+			for name := range properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				property, ok := properties[name].(map[string]interface{})
+				if !ok {
+					return unsupported(keyword)
+				}
+				if err := checkSchema(property, path+"."+name); err != nil {
+					return err
+				}
+			}
+		default:
+			if !annotationKeywords[keyword] {
+				return unsupported(keyword)
+			}
+		}
+	}
+	return nil
+}
+
+func (s jsonSchema) Validate(document []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &SchemaError{Violations: []string{fmt.Sprintf("$: invalid JSON: %v", err)}}
+	}
+	var violations []string
+	validateSchema(s.root, value, "$", &violations)
+	if len(violations) > 0 {
+		return &SchemaError{Violations: violations}
+	}
+	return nil
+}
+
+// validateSchema appends to violations the ways the value at the path does not conform to the schema.
+func validateSchema(schema map[string]interface{}, value interface{}, path string, violations *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if expected, ok := schema["type"]; ok && !matchesType(expected, value) {
+		fail("expected %v, got %s", expected, jsonType(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		fail("%s is not one of %s", marshalValue(value), marshalValue(enum))
+	}
+	if constant, ok := schema["const"]; ok && !equalValues(constant, value) {
+		fail("%s is not %s", marshalValue(value), marshalValue(constant))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := v[fmt.Sprint(name)]; !ok {
+					fail("missing required property %q", name)
+				}
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					fail("unexpected property %q", name)
+				}
+				continue
+			}
+			validateSchema(property, v[name], path+"."+name, violations)
+		}
+	case []interface{}:
+		if minItems, ok := number(schema["minItems"]); ok && float64(len(v)) < minItems {
+			fail("expected at least %v items, got %d", minItems, len(v))
+		}
+		if maxItems, ok := number(schema["maxItems"]); ok && float64(len(v)) > maxItems {
+			fail("expected at most %v items, got %d", maxItems, len(v))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if minLength, ok := number(schema["minLength"]); ok && length < minLength {
+			fail("expected at least %v characters, got %v", minLength, length)
+		}
+		if maxLength, ok := number(schema["maxLength"]); ok && length > maxLength {
+			fail("expected at most %v characters, got %v", maxLength, length)
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if minimum, ok := number(schema["minimum"]); ok && n < minimum {
+			fail("%v is less than the minimum %v", v, minimum)
+		}
+		if maximum, ok := number(schema["maximum"]); ok && n > maximum {
+			fail("%v is greater than the maximum %v", v, maximum)
+		}
+	}
+}
+
+// matchesType reports whether the value is of the type, or one of the types, of the schema.
+func matchesType(expected interface{}, value interface{}) bool {
+	types, ok := expected.([]interface{})
+	if !ok {
+		types = []interface{}{expected}
+	}
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a decoded value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if n, err := v.Float64(); err == nil && n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// number returns the value of a numeric schema keyword.
+func number(value interface{}) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equalValues(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues compares a value of the schema with a value of the document, whose numbers are json.Number.
+func equalValues(schema interface{}, value interface{}) bool {
+	return marshalValue(schema) == marshalValue(normalize(value))
+}
+
+// normalize converts the json.Number values of the document to float64, as in the schema.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Float64()
+		return n
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
+
+func marshalValue(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
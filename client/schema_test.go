@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "status", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"status": {"enum": ["open", "closed"]},
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["sku"],
+				"properties": {"sku": {"type": "string", "minLength": 3}, "quantity": {"type": "number"}}
+			}
+		}
+	}
+}`
+
+func TestDoResponseSchema(t *testing.T) {
+
+	tests := []struct {
+		name               string
+		schema             string
+		mockResponse       string
+		expected           interface{}
+		expectedViolations []string
+		expectedError      string
+	}{
+		{
+			name:         "conforming response",
+			schema:       orderSchema,
+			mockResponse: `{"id": 1, "status": "open", "items": [{"sku": "abc", "quantity": 2.5}]}`,
+			expected: map[string]interface{}{
+				"id":     float64(1),
+				"status": "open",
+				"items":  []interface{}{map[string]interface{}{"sku": "abc", "quantity": 2.5}},
+			},
+		},
+		{
+			name:         "non conforming response",
+			schema:       orderSchema,
+			mockResponse: `{"id": 0.5, "status": "pending", "items": [{"sku": "a"}, {"quantity": "2"}], "extra": true}`,
+			expectedViolations: []string{
+				`$: unexpected property "extra"`,
+				`$.id: expected integer, got number`,
+				`$.items[0].sku: expected at least 3 characters, got 1`,
+				`$.items[1]: missing required property "sku"`,
+				`$.items[1].quantity: expected number, got string`,
+				`$.status: "pending" is not one of ["open","closed"]`,
+			},
+		},
+		{
+			name:         "wrong top level type",
+			schema:       orderSchema,
+			mockResponse: `[]`,
+			expectedViolations: []string{
+				`$: expected object, got array`,
+			},
+		},
+		{
+			name:          "invalid schema",
+			schema:        `{"type": `,
+			mockResponse:  `{}`,
+			expectedError: "compiling schema",
+		},
+		{
+			name:          "unsupported keyword",
+			schema:        `{"type": "object", "oneOf": [{"required": ["id"]}, {"required": ["name"]}]}`,
+			mockResponse:  `{}`,
+			expectedError: `unsupported schema: keyword "oneOf" at $`,
+		},
+		{
+			name:          "unsupported keyword in a subschema",
+			schema:        `{"type": "object", "properties": {"items": {"type": "array", "items": {"$ref": "#/$defs/item"}}}}`,
+			mockResponse:  `{}`,
+			expectedError: `unsupported schema: keyword "$ref" at $.items[]`,
+		},
+		{
+			name:          "unsupported form of a keyword",
+			schema:        `{"type": "object", "additionalProperties": {"type": "string"}}`,
+			mockResponse:  `{}`,
+			expectedError: `unsupported schema: keyword "additionalProperties" at $`,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprint(w, tc.mockResponse)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithResponseSchema([]byte(tc.schema))
+
+			var result interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(1, calls)
+			if tc.expectedError != "" {
+				assertion.ErrorContains(err, tc.expectedError)
+				return
+			}
+			if tc.expectedViolations != nil {
+				var schemaErr *SchemaError
+				assertion.True(errors.As(err, &schemaErr))
+				assertion.ErrorIs(err, ErrSchemaViolation)
+				assertion.Equal(tc.expectedViolations, schemaErr.Violations)
+				assertion.Nil(result)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+// schemaFunc adapts a function to the SchemaValidator interface.
+type schemaFunc func(document []byte) error
+
+func (f schemaFunc) Validate(document []byte) error {
+	return f(document)
+}
+
+func TestWithSchemaCompiler(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	defer svr.Close()
+
+	var compiled, validated string
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithSchemaCompiler(func(schema []byte) (SchemaValidator, error) {
+			compiled = string(schema)
+			return schemaFunc(func(document []byte) error {
+				validated = string(document)
+				return nil
+			}), nil
+		}).
+		WithResponseSchema([]byte(`{"type": "object"}`))
+
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	assertion.NoError(err)
+	assertion.Equal(`{"type": "object"}`, compiled)
+	assertion.Equal(`{"message": "success"}`, validated)
+}
+
+func TestDoRequestSchema(t *testing.T) {
+
+	tests := []struct {
+		name               string
+		request            interface{}
+		expectedCalls      int
+		expectedViolations []string
+	}{
+		{
+			name:          "valid request",
+			request:       map[string]interface{}{"id": 1, "status": "open", "items": []map[string]interface{}{{"sku": "abc"}}},
+			expectedCalls: 1,
+		},
+		{
+			name:    "invalid request",
+			request: map[string]interface{}{"id": -1, "status": "open", "items": []map[string]interface{}{}},
+			expectedViolations: []string{
+				`$.id: -1 is less than the minimum 1`,
+				`$.items: expected at least 1 items, got 0`,
+			},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("POST").
+				WithMaxAttempts(3).
+				WithRequestSchema([]byte(orderSchema))
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), tc.request, &result)
+			assertion.Equal(tc.expectedCalls, calls)
+			if tc.expectedViolations != nil {
+				var schemaErr *SchemaError
+				assertion.True(errors.As(err, &schemaErr))
+				assertion.Equal(tc.expectedViolations, schemaErr.Violations)
+				assertion.Equal(int64(0), status)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrShutdown is returned by calls interrupted by Shutdown, or made after it.
+var ErrShutdown = errors.New("client shut down")
+
+// lifetime returns the context canceled by Shutdown, creating it on first use.
+func (r *RestClient) lifetime() context.Context {
+	r.lifetimeOnce.Do(func() {
+		r.lifetimeCtx, r.shutdown = context.WithCancel(context.Background())
+	})
+	return r.lifetimeCtx
+}
+
+// Shutdown cancels the calls in flight, which return promptly with an error wrapping ErrShutdown.
+// Calls made after Shutdown fail right away with ErrShutdown.
+func (r *RestClient) Shutdown() {
+	r.lifetime()
+	r.shutdown()
+}
+
+// WithBaseContext sets a context every call derives from, on top of the one it is made with:
+// a call fails once either of them is canceled, is bounded by the tighter of their deadlines and
+// sees the values of both, the ones of the call context first. Calls made once the base context
+// is canceled fail right away.
+func (r *RestClient) WithBaseContext(ctx context.Context) *RestClient {
+	r.baseCtx = ctx
+	return r
+}
+
+// mergedContext looks up values in the call context, then in the base context.
+type mergedContext struct {
+	context.Context
+	base context.Context
+}
+
+func (c mergedContext) Value(key interface{}) interface{} {
+	if value := c.Context.Value(key); value != nil {
+		return value
+	}
+	return c.base.Value(key)
+}
+
+// shutdownContext derives a context from ctx that is also canceled when the client is shut down
+// or the base context is done. The returned function must be called once the call is done.
+func (r *RestClient) shutdownContext(ctx context.Context) (context.Context, func(), error) {
+	lifetime := r.lifetime()
+	if lifetime.Err() != nil {
+		return ctx, func() {}, ErrShutdown
+	}
+
+	base := r.baseCtx
+	var baseDone <-chan struct{}
+	cancelDeadline := context.CancelFunc(func() {})
+	if base != nil {
+		if base.Err() != nil {
+			return ctx, func() {}, context.Cause(base)
+		}
+		baseDone = base.Done()
+		ctx = mergedContext{Context: ctx, base: base}
+		if deadline, ok := base.Deadline(); ok {
+			ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-lifetime.Done():
+			cancel(ErrShutdown)
+		case <-baseDone:
+			cancel(context.Cause(base))
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		cancel(nil)
+		cancelDeadline()
+	}, nil
+}
+
+// shutdownError wraps the error of a call interrupted by Shutdown with ErrShutdown.
+func shutdownError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrShutdown) || !errors.Is(context.Cause(ctx), ErrShutdown) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrShutdown, err)
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	const calls = 10
+
+	var received int32
+	arrived := make(chan struct{}, calls*2)
+	release := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		arrived <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer svr.Close()
+	defer close(release)
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMaxAttempts(3)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				_, err := m.DoInto(context.Background(), nil, io.Discard)
+				errs <- err
+				return
+			}
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			errs <- err
+		}()
+	}
+	for i := 0; i < calls; i++ {
+		<-arrived
+	}
+
+	start := time.Now()
+	m.Shutdown()
+	wg.Wait()
+	close(errs)
+
+	assertion.Less(time.Since(start), time.Second)
+	for err := range errs {
+		assertion.ErrorIs(err, ErrShutdown)
+	}
+
+	before := atomic.LoadInt32(&received)
+	var result map[string]interface{}
+	status, err := m.Do(context.Background(), nil, &result)
+	assertion.ErrorIs(err, ErrShutdown)
+	assertion.Equal(int64(internalStatusRequestError), status)
+	_, err = m.DoInto(context.Background(), nil, io.Discard)
+	assertion.ErrorIs(err, ErrShutdown)
+	assertion.Equal(before, atomic.LoadInt32(&received))
+
+	// shutting down again is a no-op
+	m.Shutdown()
+}
+
+type baseKey struct{}
+
+func TestWithBaseContext(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var received int32
+	arrived := make(chan struct{}, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		io.ReadAll(r.Body)
+		if r.URL.Path == "/slow" {
+			arrived <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`{"message": "success"}`))
+	}))
+	defer svr.Close()
+
+	base, cancel := context.WithCancel(context.WithValue(context.Background(), baseKey{}, "base"))
+	defer cancel()
+
+	var value interface{}
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMaxAttempts(3).
+		WithBaseContext(base).
+		WithBodyTransform(func(ctx context.Context, body []byte) ([]byte, error) {
+			value = ctx.Value(baseKey{})
+			return body, nil
+		})
+
+	// the values of the base context are seen by the call
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	assertion.NoError(err)
+	assertion.Equal("base", value)
+
+	// canceling the base context interrupts the calls in flight
+	go func() {
+		<-arrived
+		cancel()
+	}()
+	start := time.Now()
+	_, err = m.Get(context.Background(), svr.URL+"/slow", &result)
+	assertion.ErrorIs(err, context.Canceled)
+	assertion.Less(time.Since(start), time.Second)
+
+	// and makes the next ones fail without being sent
+	before := atomic.LoadInt32(&received)
+	status, err := m.Do(context.Background(), nil, &result)
+	assertion.ErrorIs(err, context.Canceled)
+	assertion.Equal(int64(internalStatusRequestError), status)
+	assertion.Equal(before, atomic.LoadInt32(&received))
+}
+
+func TestWithBaseContextDeadline(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer svr.Close()
+
+	base, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMaxAttempts(1).
+		WithBaseContext(base)
+
+	// the tighter deadline of the base context applies to a call made with a looser one
+	ctx, cancelCall := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelCall()
+
+	start := time.Now()
+	var result map[string]interface{}
+	_, err := m.Do(ctx, nil, &result)
+	assertion.ErrorIs(err, context.DeadlineExceeded)
+	assertion.Less(time.Since(start), time.Second)
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DoJSONStream makes an HTTP request whose response is a JSON array, decoding it one element
+// at a time and passing each one to the handler instead of buffering the whole array.
+// Attempts are retried as in Do until the response starts streaming; an error returned by
+// the handler, or a malformed element, stops the stream and is returned. The body of a status
+// other than a success one is not streamed but returned in a *StatusError.
+func (r *RestClient) DoJSONStream(ctx context.Context, request interface{}, handler func(json.RawMessage) error) (int64, error) {
+	ctx, done, err := r.shutdownContext(ctx)
+	if err != nil {
+		return internalStatusRequestError, err
+	}
+	defer done()
+
+	status, err := r.doJSONStream(ctx, request, handler)
+	// the total timeout is told apart from the deadline of the call context here, past its own context
+	return status, shutdownError(ctx, clientTimeout(ctx, err))
+}
+
+func (r *RestClient) doJSONStream(ctx context.Context, request interface{}, handler func(json.RawMessage) error) (int64, error) {
+
+	started := r.now()
+	resp, status, err := r.open(ctx, request)
+	if err != nil {
+		return status, err
+	}
+	defer drainAndClose(resp.Body)
+
+	reader, err := r.decompress(resp)
+	if err != nil {
+		return status, err
+	}
+	defer reader.Close()
+
+	limited, truncated := r.truncate(reader)
+	decoder := json.NewDecoder(limited)
+
+	token, err := decoder.Token()
+	if err != nil {
+		if truncatedErr := truncated(); truncatedErr != nil {
+			return status, truncatedErr
+		}
+		return status, fmt.Errorf("reading stream: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return status, fmt.Errorf("reading stream: expected a JSON array, got %v", token)
+	}
+
+	for index := 0; decoder.More(); index++ {
+		var element json.RawMessage
+		if err = decoder.Decode(&element); err != nil {
+			// the elements within the limit were handled, the one cut by the limit is dropped
+			if truncatedErr := truncated(); truncatedErr != nil {
+				return status, truncatedErr
+			}
+			r.logger().ErrorContext(ctx, "error decoding stream element",
+				"err", err,
+				"index", index,
+			)
+			return status, fmt.Errorf("decoding stream element %d: %w", index, err)
+		}
+		if err = handler(element); err != nil {
+			return status, err
+		}
+	}
+
+	if _, err = decoder.Token(); err != nil {
+		if truncatedErr := truncated(); truncatedErr != nil {
+			return status, truncatedErr
+		}
+		return status, fmt.Errorf("reading stream: %w", err)
+	}
+
+	r.checkLatency(ctx, resp.Request.URL.String(), started)
+	return status, nil
+}
+
+// DoInto makes an HTTP request and copies the response body into the writer, e.g. a file,
+// instead of decoding it. Attempts are retried as in Do until the body starts being copied,
+// after which failures are returned as they are. The body of a status other than a success one
+// is not copied but returned in a *StatusError.
+func (r *RestClient) DoInto(ctx context.Context, request interface{}, w io.Writer) (int64, error) {
+	ctx, done, err := r.shutdownContext(ctx)
+	if err != nil {
+		return internalStatusRequestError, err
+	}
+	defer done()
+
+	status, err := r.doInto(ctx, request, w)
+	// the total timeout is told apart from the deadline of the call context here, past its own context
+	return status, shutdownError(ctx, clientTimeout(ctx, err))
+}
+
+func (r *RestClient) doInto(ctx context.Context, request interface{}, w io.Writer) (int64, error) {
+
+	started := r.now()
+	resp, status, err := r.open(ctx, request)
+	if err != nil {
+		return status, err
+	}
+	defer drainAndClose(resp.Body)
+
+	reader, err := r.decompress(resp)
+	if err != nil {
+		return status, err
+	}
+	defer reader.Close()
+
+	limited, truncated := r.truncate(reader)
+	if _, err = io.Copy(w, limited); err != nil {
+		return status, err
+	}
+	if err = truncated(); err != nil {
+		return status, err
+	}
+	r.checkLatency(ctx, resp.Request.URL.String(), started)
+	return status, nil
+}
+
+// releaseReadCloser releases the request slot when the response body is closed.
+type releaseReadCloser struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseReadCloser) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// timeoutReadCloser wraps the errors of reading the body with ErrClientTimeout when a timeout of
+// the client cut the read short.
+type timeoutReadCloser struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (t *timeoutReadCloser) Read(b []byte) (int, error) {
+	n, err := t.ReadCloser.Read(b)
+	return n, clientTimeout(t.ctx, err)
+}
+
+// open sends the request, retrying it as Do does, and returns the response of the last attempt
+// with its body unread, along with its status. The body of a status other than a success one is
+// read instead and returned in a *StatusError, so that it is retried as in Do. The caller must
+// close the body, which the total and request timeouts keep bounding until then.
+func (r *RestClient) open(ctx context.Context, request interface{}) (*http.Response, int64, error) {
+
+	ctx, cancel := r.totalContext(ctx)
+	ctx = r.withRequestID(ctx)
+	result := &Result{RequestID: generatedRequestID(ctx)}
+
+	body, err := r.encode(request)
+	if err != nil {
+		r.logger().ErrorContext(ctx, "error encoding request",
+			"err", err,
+		)
+		cancel()
+		return nil, internalStatusRequestError, err
+	}
+	if err = r.validateRequest(body); err != nil {
+		r.logger().ErrorContext(ctx, "request does not match the schema",
+			"err", err,
+		)
+		cancel()
+		return nil, internalStatusRequestError, err
+	}
+	defer body.close()
+	if body, err = body.buffer(); err != nil {
+		cancel()
+		return nil, internalStatusRequestError, err
+	}
+
+	client := r.httpClient()
+	start := r.startIndex()
+	attemptURL := func(i int64) string {
+		return r.attemptURL(start + i)
+	}
+
+	// the response of an attempt is left open for the caller, unless the attempt is retried
+	var resp *http.Response
+	discard := func() {
+		if resp != nil {
+			drainAndClose(resp.Body)
+			resp = nil
+		}
+	}
+	_, err = r.runAttempts(ctx, r.method, body, result, attemptURL, func(attemptCtx context.Context, i int64, url string) attemptOutcome {
+		discard()
+		result.Status, result.Header = internalStatusRequestError, nil
+
+		// the request timeout goes on bounding the body once the response is handed over
+		attemptCtx, cancelAttempt := r.requestContext(attemptCtx, i)
+		req, err := r.newRequest(attemptCtx, r.method, url, nil, body)
+		if err != nil {
+			cancelAttempt()
+			return attemptOutcome{err: err}
+		}
+
+		slot, err := r.acquireSlot(attemptCtx, req.URL.Host)
+		if err != nil {
+			cancelAttempt()
+			return attemptOutcome{err: err}
+		}
+		release := func() {
+			slot()
+			cancelAttempt()
+		}
+
+		attemptClient := r.attemptClient(attemptCtx, client, i)
+		req.Body = body.track(req.Body)
+		resp, err = r.send(&attemptClient, req)
+		if err != nil && resp != nil {
+			err = responseError(resp, err)
+		}
+		if err != nil {
+			release()
+			resp = nil
+			r.logger().ErrorContext(ctx, "error making request",
+				"err", err,
+			)
+			return attemptOutcome{err: clientTimeout(ctx, err)}
+		}
+
+		// the slot is held until the caller is done with the body, whose read errors are told
+		// apart as the ones of the request
+		resp.Body = &releaseReadCloser{ReadCloser: &timeoutReadCloser{ReadCloser: resp.Body, ctx: ctx}, release: release}
+		if err = r.inspectResponse(attemptCtx, resp); err != nil {
+			result.Header = resp.Header
+			discard()
+			return attemptOutcome{err: err}
+		}
+		result.Status, result.Header = int64(resp.StatusCode), resp.Header
+		if !r.success(result.Status) {
+			data, _ := r.readBody(attemptCtx, resp)
+			discard()
+			return attemptOutcome{body: data, err: r.statusError(attemptCtx, url, result.Status, data)}
+		}
+		r.trackDownload(resp)
+		return attemptOutcome{}
+	})
+	if err != nil {
+		discard()
+		cancel()
+		return nil, result.Status, err
+	}
+	resp.Body = &releaseReadCloser{ReadCloser: resp.Body, release: cancel}
+	return resp, result.Status, nil
+}
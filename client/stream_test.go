@@ -0,0 +1,421 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoJSONStream(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		mockResponse   string
+		handlerError   error
+		expected       []string
+		expectedStatus int64
+		expectedError  error
+	}{
+		{
+			name:           "array",
+			mockResponse:   `[{"id": 1}, {"id": 2}, {"id": 3}]`,
+			expected:       []string{`{"id": 1}`, `{"id": 2}`, `{"id": 3}`},
+			expectedStatus: 200,
+		},
+		{
+			name:           "empty array",
+			mockResponse:   `[]`,
+			expectedStatus: 200,
+		},
+		{
+			name:           "malformed element mid-stream",
+			mockResponse:   `[{"id": 1}, {"id": 2`,
+			expected:       []string{`{"id": 1}`},
+			expectedStatus: 200,
+			expectedError:  fmt.Errorf("decoding stream element 1"),
+		},
+		{
+			name:           "not an array",
+			mockResponse:   `{"id": 1}`,
+			expectedStatus: 200,
+			expectedError:  fmt.Errorf("expected a JSON array"),
+		},
+		{
+			name:           "handler error stops the stream",
+			mockResponse:   `[{"id": 1}, {"id": 2}, {"id": 3}]`,
+			handlerError:   fmt.Errorf("stop"),
+			expected:       []string{`{"id": 1}`},
+			expectedStatus: 200,
+			expectedError:  fmt.Errorf("stop"),
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.mockResponse)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+
+			var elements []string
+			status, err := m.DoJSONStream(context.Background(), nil, func(element json.RawMessage) error {
+				elements = append(elements, string(element))
+				return tc.handlerError
+			})
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expected, elements)
+			if err != nil {
+				assertion.Contains(err.Error(), tc.expectedError.Error())
+				return
+			}
+			assertion.Nil(tc.expectedError)
+		})
+	}
+}
+
+func TestDoJSONStreamLargeArray(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	const size = 10000
+	firstReceived := make(chan struct{})
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 0}`)
+		w.(http.Flusher).Flush()
+
+		// the rest of the array is only written once the client handled the first element
+		select {
+		case <-firstReceived:
+		case <-time.After(time.Second * 5):
+			return
+		}
+		for i := 1; i < size; i++ {
+			fmt.Fprintf(w, `, {"id": %d}`, i)
+		}
+		fmt.Fprint(w, `]`)
+	}))
+	defer svr.Close()
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1)
+
+	count := 0
+	status, err := m.DoJSONStream(context.Background(), nil, func(element json.RawMessage) error {
+		var item struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(element, &item); err != nil {
+			return err
+		}
+		assertion.Equal(count, item.ID)
+		if count == 0 {
+			close(firstReceived)
+		}
+		count++
+		return nil
+	})
+	assertion.NoError(err)
+	assertion.Equal(int64(200), status)
+	assertion.Equal(size, count)
+}
+
+func TestDoJSONStreamTimeouts(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		requestTimeout time.Duration
+		totalTimeout   time.Duration
+	}{
+		{
+			name:           "request timeout while streaming",
+			requestTimeout: time.Millisecond * 50,
+		},
+		{
+			name:         "total timeout while streaming",
+			totalTimeout: time.Millisecond * 50,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				fmt.Fprint(w, `[{"id": 1},`)
+				w.(http.Flusher).Flush()
+				// the rest of the array never comes
+				<-r.Context().Done()
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithRequestTimeout(tc.requestTimeout).
+				WithTotalTimeout(tc.totalTimeout)
+
+			var elements []string
+			status, err := m.DoJSONStream(context.Background(), nil, func(element json.RawMessage) error {
+				elements = append(elements, string(element))
+				return nil
+			})
+			assertion.ErrorIs(err, context.DeadlineExceeded)
+			assertion.ErrorIs(err, ErrClientTimeout)
+			assertion.Equal(int64(200), status)
+			assertion.Equal([]string{`{"id": 1}`}, elements)
+		})
+	}
+}
+
+func TestDoInto(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		statuses        []int
+		retryOn         []int
+		mockResponse    string
+		maxResponseSize int64
+		expected        string
+		expectedStatus  int64
+		expectedCalls   int
+		expectedError   error
+	}{
+		{
+			name:           "body copied into the writer",
+			statuses:       []int{200},
+			mockResponse:   strings.Repeat("data", 1024),
+			expected:       strings.Repeat("data", 1024),
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "retried before streaming",
+			statuses:       []int{503, 200},
+			mockResponse:   "content",
+			expected:       "content",
+			expectedStatus: 200,
+			expectedCalls:  2,
+		},
+		{
+			name:           "retried on the statuses set with WithRetryOn",
+			statuses:       []int{429, 200},
+			retryOn:        []int{429},
+			mockResponse:   "content",
+			expected:       "content",
+			expectedStatus: 200,
+			expectedCalls:  2,
+		},
+		{
+			name:           "retries exhausted",
+			statuses:       []int{503, 503, 503},
+			mockResponse:   "unavailable",
+			expectedStatus: 503,
+			expectedCalls:  3,
+			expectedError:  &StatusError{Status: 503, Body: []byte("unavailable")},
+		},
+		{
+			name:            "body within the limit",
+			statuses:        []int{200},
+			mockResponse:    "content",
+			maxResponseSize: 7,
+			expected:        "content",
+			expectedStatus:  200,
+			expectedCalls:   1,
+		},
+		{
+			name:            "body larger than the limit",
+			statuses:        []int{200},
+			mockResponse:    "content",
+			maxResponseSize: 4,
+			expected:        "cont",
+			expectedStatus:  200,
+			expectedCalls:   1,
+			expectedError:   ErrTruncated,
+		},
+		{
+			name:           "error status not copied",
+			statuses:       []int{404},
+			mockResponse:   "not found",
+			expectedStatus: 404,
+			expectedCalls:  1,
+			expectedError:  &StatusError{Status: 404, Body: []byte("not found")},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[calls])
+				calls++
+				fmt.Fprint(w, tc.mockResponse)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(3).
+				WithIntervalSeconds(0).
+				WithRetryOn(tc.retryOn...).
+				WithMaxResponseSize(tc.maxResponseSize)
+
+			var buf bytes.Buffer
+			status, err := m.DoInto(context.Background(), nil, &buf)
+			assertion.Equal(tc.expectedStatus, status)
+			assertion.Equal(tc.expectedCalls, calls)
+			assertion.Equal(tc.expected, buf.String())
+			if err != nil {
+				var statusErr *StatusError
+				if errors.As(err, &statusErr) {
+					assertion.Equal(tc.expectedError, statusErr)
+					return
+				}
+				assertion.ErrorIs(err, tc.expectedError)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+		})
+	}
+}
+
+func TestDoIntoTimeouts(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		requestTimeout time.Duration
+		totalTimeout   time.Duration
+	}{
+		{
+			name:           "request timeout",
+			requestTimeout: time.Millisecond * 50,
+		},
+		{
+			name:         "total timeout",
+			totalTimeout: time.Millisecond * 50,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				// the response never comes
+				<-r.Context().Done()
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithRequestTimeout(tc.requestTimeout).
+				WithTotalTimeout(tc.totalTimeout)
+
+			var buf bytes.Buffer
+			status, err := m.DoInto(context.Background(), nil, &buf)
+			assertion.ErrorIs(err, context.DeadlineExceeded)
+			assertion.Equal(int64(internalStatusRequestError), status)
+			assertion.Empty(buf.String())
+		})
+	}
+}
+
+func TestDoIntoClientTimeout(t *testing.T) {
+
+	tests := []struct {
+		name                  string
+		timeout               time.Duration
+		requestTimeout        time.Duration
+		totalTimeout          time.Duration
+		contextTimeout        time.Duration
+		expectedClientTimeout bool
+	}{
+		{
+			name:                  "client timeout while copying the body",
+			timeout:               time.Millisecond * 50,
+			expectedClientTimeout: true,
+		},
+		{
+			name:                  "request timeout while copying the body",
+			requestTimeout:        time.Millisecond * 50,
+			expectedClientTimeout: true,
+		},
+		{
+			name:                  "total timeout while copying the body",
+			totalTimeout:          time.Millisecond * 50,
+			expectedClientTimeout: true,
+		},
+		{
+			name:           "context deadline while copying the body",
+			contextTimeout: time.Millisecond * 50,
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "partial")
+				w.(http.Flusher).Flush()
+				// the rest of the body never comes
+				<-r.Context().Done()
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithTimeout(tc.timeout).
+				WithRequestTimeout(tc.requestTimeout).
+				WithTotalTimeout(tc.totalTimeout)
+
+			ctx := context.Background()
+			if tc.contextTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.contextTimeout)
+				defer cancel()
+			}
+
+			var buf bytes.Buffer
+			status, err := m.DoInto(ctx, nil, &buf)
+			assertion.Error(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal("partial", buf.String())
+			assertion.Equal(tc.expectedClientTimeout, errors.Is(err, ErrClientTimeout))
+		})
+	}
+}
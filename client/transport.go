@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	proxyAuthorizationHeader = "Proxy-Authorization"
+)
+
+// httpTransport returns the transport owned by the client, building it on first use
+// so connections are pooled across calls.
+func (r *RestClient) httpTransport() *http.Transport {
+	r.transportMu.Lock()
+	defer r.transportMu.Unlock()
+	if r.transport == nil {
+		r.transport = r.newTransport()
+	}
+	return r.transport
+}
+
+// Close releases the idle connections pooled by the transport of the client. It is safe to call
+// once done with the client; a client used again after Close opens new connections as needed.
+func (r *RestClient) Close() {
+	r.transportMu.Lock()
+	defer r.transportMu.Unlock()
+	if r.transport != nil {
+		r.transport.CloseIdleConnections()
+	}
+}
+
+// resetTransport discards the transport so the next call builds one with the current settings.
+// The idle connections of a transport shared with a clone are left to the other clients.
+func (r *RestClient) resetTransport() {
+	r.transportMu.Lock()
+	defer r.transportMu.Unlock()
+	if r.transport != nil && !r.sharedTransport {
+		r.transport.CloseIdleConnections()
+	}
+	r.transport = nil
+	r.sharedTransport = false
+}
+
+// shareTransport returns the transport of the client, to be shared with a clone.
+func (r *RestClient) shareTransport() *http.Transport {
+	r.transportMu.Lock()
+	defer r.transportMu.Unlock()
+	if r.transport == nil {
+		r.transport = r.newTransport()
+	}
+	r.sharedTransport = true
+	return r.transport
+}
+
+func (r *RestClient) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = r.headerTimeout
+	transport.DisableKeepAlives = r.disableKeepAlives
+	if r.dialOverride != "" {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, r.dialOverride)
+		}
+	}
+	if r.tlsTimeout > 0 {
+		transport.TLSHandshakeTimeout = r.tlsTimeout
+	}
+	if r.proxy != "" {
+		transport.Proxy = func(*http.Request) (*url.URL, error) {
+			return url.Parse(r.proxy)
+		}
+		if auth := r.proxyAuthorization(); auth != "" {
+			transport.ProxyConnectHeader = http.Header{proxyAuthorizationHeader: {auth}}
+		}
+	}
+	return transport
+}
+
+// proxyAuthorization returns the value of the Proxy-Authorization header, if proxy credentials are set.
+func (r *RestClient) proxyAuthorization() string {
+	if r.proxyUsername == "" && r.proxyPassword == "" {
+		return ""
+	}
+	credentials := r.proxyUsername + ":" + r.proxyPassword
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+// setProxyAuthorization sets the proxy credentials on plain HTTP requests, which are sent to
+// the proxy as is. HTTPS requests carry them on the CONNECT request instead.
+func (r *RestClient) setProxyAuthorization(req *http.Request) {
+	if r.proxy == "" || req.URL.Scheme != "http" {
+		return
+	}
+	if auth := r.proxyAuthorization(); auth != "" {
+		req.Header.Set(proxyAuthorizationHeader, auth)
+	}
+}
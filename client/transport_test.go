@@ -0,0 +1,439 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoProxyBasicAuth(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		username       string
+		password       string
+		expected       interface{}
+		expectedStatus int64
+		expectedError  error
+	}{
+		{
+			name:           "with credentials",
+			username:       "john",
+			password:       "secret",
+			expected:       map[string]interface{}{"url": "http://upstream.test/items"},
+			expectedStatus: 200,
+		},
+		{
+			name:           "wrong credentials",
+			username:       "john",
+			password:       "wrong",
+			expectedStatus: 407,
+			expectedError:  &StatusError{Status: 407, Body: []byte(`{"error": "proxy authentication required"}`)},
+		},
+		{
+			name:           "without credentials",
+			expectedStatus: 407,
+			expectedError:  &StatusError{Status: 407, Body: []byte(`{"error": "proxy authentication required"}`)},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Proxy-Authorization") != "Basic am9objpzZWNyZXQ=" {
+					w.WriteHeader(http.StatusProxyAuthRequired)
+					fmt.Fprint(w, `{"error": "proxy authentication required"}`)
+					return
+				}
+				fmt.Fprintf(w, `{"url": %q}`, r.URL.String())
+			}))
+			defer proxy.Close()
+
+			m := NewRestClient().
+				WithURL("http://upstream.test/items").
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithProxy(proxy.URL).
+				WithProxyBasicAuth(tc.username, tc.password)
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.Equal(tc.expectedStatus, status)
+			if err != nil {
+				assertion.Equal(tc.expectedError, err)
+				return
+			}
+			assertion.Nil(tc.expectedError)
+			assertion.Equal(tc.expected, result)
+		})
+	}
+}
+
+func TestDoProxyBasicAuthConnect(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		username      string
+		password      string
+		expected      interface{}
+		expectedError string
+	}{
+		{
+			name:     "with credentials",
+			username: "john",
+			password: "secret",
+			expected: map[string]interface{}{"message": "success"},
+		},
+		{
+			name:          "wrong credentials",
+			username:      "john",
+			password:      "wrong",
+			expectedError: "Proxy Authentication Required",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var upstreamAuth []string
+			upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreamAuth = append(upstreamAuth, r.Header.Get("Proxy-Authorization"))
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer upstream.Close()
+
+			// the proxy tunnels the HTTPS requests to the upstream once their CONNECT request is authorized
+			var connects []*http.Request
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				connects = append(connects, r)
+				if r.Method != http.MethodConnect || r.Header.Get("Proxy-Authorization") != "Basic am9objpzZWNyZXQ=" {
+					w.WriteHeader(http.StatusProxyAuthRequired)
+					return
+				}
+				target, err := net.Dial("tcp", r.Host)
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				defer target.Close()
+				w.WriteHeader(http.StatusOK)
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				go io.Copy(target, buf)
+				io.Copy(conn, target)
+			}))
+			defer proxy.Close()
+
+			m := NewRestClient().
+				WithURL(upstream.URL+"/items").
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithProxy(proxy.URL).
+				WithProxyBasicAuth(tc.username, tc.password)
+			m.httpTransport().TLSClientConfig = upstream.Client().Transport.(*http.Transport).TLSClientConfig
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			if assertion.Len(connects, 1) {
+				assertion.Equal(http.MethodConnect, connects[0].Method)
+				assertion.Equal(upstream.Listener.Addr().String(), connects[0].Host)
+			}
+			if tc.expectedError != "" {
+				assertion.ErrorContains(err, tc.expectedError)
+				assertion.Empty(upstreamAuth)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(tc.expected, result)
+			// the credentials are meant for the proxy, not for the upstream behind the tunnel
+			assertion.Equal([]string{""}, upstreamAuth)
+		})
+	}
+}
+
+func TestDoResponseHeaderTimeout(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		headerDelay   time.Duration
+		bodyDelay     time.Duration
+		timeout       time.Duration
+		expectedError string
+	}{
+		{
+			name:          "headers slower than the timeout",
+			headerDelay:   time.Millisecond * 300,
+			expectedError: "timeout awaiting response headers",
+		},
+		{
+			name:      "body slower than the header timeout",
+			bodyDelay: time.Millisecond * 300,
+		},
+		{
+			name:          "body slower than the overall timeout",
+			bodyDelay:     time.Millisecond * 300,
+			timeout:       time.Millisecond * 200,
+			expectedError: "while reading body",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tc.headerDelay)
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				time.Sleep(tc.bodyDelay)
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithTimeout(tc.timeout).
+				WithResponseHeaderTimeout(time.Millisecond * 100)
+
+			var result map[string]interface{}
+			_, err := m.Do(context.Background(), nil, &result)
+			if tc.expectedError != "" {
+				assertion.ErrorContains(err, tc.expectedError)
+				return
+			}
+			assertion.NoError(err)
+			assertion.Equal(map[string]interface{}{"message": "success"}, result)
+		})
+	}
+}
+
+func TestDoTLSHandshakeTimeout(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	// the listener accepts connections but never answers the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assertion.NoError(err)
+	defer listener.Close()
+
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	m := NewRestClient().
+		WithURL("https://" + listener.Addr().String()).
+		WithMethod("GET").
+		WithMaxAttempts(1).
+		WithTLSHandshakeTimeout(time.Millisecond * 100)
+
+	start := time.Now()
+	var result map[string]interface{}
+	_, err = m.Do(context.Background(), nil, &result)
+	assertion.ErrorContains(err, "TLS handshake timeout")
+	assertion.Less(time.Since(start), time.Second)
+}
+
+func TestDoDisableKeepAlives(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		disable        bool
+		expectedReused []bool
+	}{
+		{
+			name:           "connections reused by default",
+			expectedReused: []bool{false, true, true},
+		},
+		{
+			name:           "keep-alives disabled",
+			disable:        true,
+			expectedReused: []bool{false, false, false},
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL).
+				WithMethod("GET").
+				WithMaxAttempts(1)
+			if tc.disable {
+				m.WithDisableKeepAlives()
+			}
+
+			var reused []bool
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					reused = append(reused, info.Reused)
+				},
+			})
+			for range tc.expectedReused {
+				var result map[string]interface{}
+				_, err := m.Do(ctx, nil, &result)
+				assertion.NoError(err)
+			}
+			assertion.Equal(tc.expectedReused, reused)
+		})
+	}
+}
+
+func TestDoDialOverride(t *testing.T) {
+
+	tests := []struct {
+		name               string
+		tls                bool
+		url                string
+		expectedHost       string
+		expectedServerName string
+	}{
+		{
+			name:         "plain http",
+			url:          "http://api.example.com:8080/items",
+			expectedHost: "api.example.com:8080",
+		},
+		{
+			name:               "https",
+			tls:                true,
+			url:                "https://example.com/items",
+			expectedHost:       "example.com",
+			expectedServerName: "example.com",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var host, serverName string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				host = r.Host
+				if r.TLS != nil {
+					serverName = r.TLS.ServerName
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			})
+			svr := httptest.NewServer(handler)
+			if tc.tls {
+				svr.Close()
+				svr = httptest.NewTLSServer(handler)
+			}
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(tc.url).
+				WithMethod("GET").
+				WithMaxAttempts(1).
+				WithDialOverride(svr.Listener.Addr().String())
+			if tc.tls {
+				// the certificate of the test server is valid for example.com
+				m.httpTransport().TLSClientConfig = svr.Client().Transport.(*http.Transport).TLSClientConfig
+			}
+
+			var result map[string]interface{}
+			status, err := m.Do(context.Background(), nil, &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(tc.expectedHost, host)
+			assertion.Equal(tc.expectedServerName, serverName)
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+
+	assertion := assert.New(t)
+
+	var mu sync.Mutex
+	states := make(map[net.Conn]http.ConnState)
+
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message": "success"}`)
+	}))
+	svr.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states[conn] = state
+	}
+	svr.Start()
+	defer svr.Close()
+
+	countState := func(state http.ConnState) int {
+		mu.Lock()
+		defer mu.Unlock()
+		count := 0
+		for _, s := range states {
+			if s == state {
+				count++
+			}
+		}
+		return count
+	}
+
+	m := NewRestClient().
+		WithURL(svr.URL).
+		WithMethod("GET").
+		WithMaxAttempts(1)
+
+	// closing a client that was never used is a no-op
+	m.Close()
+
+	var result map[string]interface{}
+	_, err := m.Do(context.Background(), nil, &result)
+	assertion.NoError(err)
+
+	assertion.Eventually(func() bool {
+		return countState(http.StateIdle) == 1
+	}, time.Second, time.Millisecond*10)
+
+	m.Close()
+
+	assertion.Eventually(func() bool {
+		return countState(http.StateClosed) == 1 && countState(http.StateIdle) == 0
+	}, time.Second, time.Millisecond*10)
+}
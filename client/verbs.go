@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Get makes a GET request to the URL, decoding the response into out. Like the other verb
+// methods, it uses the settings of the client (headers, retries, timeouts) but overrides its
+// method and URL for this call alone, so the client can be shared by concurrent calls.
+func (r *RestClient) Get(ctx context.Context, url string, out interface{}) (int64, error) {
+	return r.verb(ctx, http.MethodGet, url, nil, out)
+}
+
+// Post makes a POST request to the URL with the encoded body, decoding the response into out.
+func (r *RestClient) Post(ctx context.Context, url string, body interface{}, out interface{}) (int64, error) {
+	return r.verb(ctx, http.MethodPost, url, body, out)
+}
+
+// Put makes a PUT request to the URL with the encoded body, decoding the response into out.
+func (r *RestClient) Put(ctx context.Context, url string, body interface{}, out interface{}) (int64, error) {
+	return r.verb(ctx, http.MethodPut, url, body, out)
+}
+
+// Patch makes a PATCH request to the URL with the encoded body, decoding the response into out.
+func (r *RestClient) Patch(ctx context.Context, url string, body interface{}, out interface{}) (int64, error) {
+	return r.verb(ctx, http.MethodPatch, url, body, out)
+}
+
+// Delete makes a DELETE request to the URL, decoding the response into out.
+func (r *RestClient) Delete(ctx context.Context, url string, out interface{}) (int64, error) {
+	return r.verb(ctx, http.MethodDelete, url, nil, out)
+}
+
+func (r *RestClient) verb(ctx context.Context, method string, url string, body interface{}, out interface{}) (int64, error) {
+	result, err := r.doWith(ctx, callOptions{method: method, url: url}, body, out)
+	return result.Status, err
+}
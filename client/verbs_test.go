@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerbs(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		call           func(m *RestClient, url string, out interface{}) (int64, error)
+		expectedMethod string
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name: "get",
+			call: func(m *RestClient, url string, out interface{}) (int64, error) {
+				return m.Get(context.Background(), url, out)
+			},
+			expectedMethod: "GET",
+		},
+		{
+			name: "post",
+			call: func(m *RestClient, url string, out interface{}) (int64, error) {
+				return m.Post(context.Background(), url, map[string]string{"name": "john"}, out)
+			},
+			expectedMethod: "POST",
+			expectedBody:   map[string]interface{}{"name": "john"},
+		},
+		{
+			name: "put",
+			call: func(m *RestClient, url string, out interface{}) (int64, error) {
+				return m.Put(context.Background(), url, map[string]string{"name": "jane"}, out)
+			},
+			expectedMethod: "PUT",
+			expectedBody:   map[string]interface{}{"name": "jane"},
+		},
+		{
+			name: "patch",
+			call: func(m *RestClient, url string, out interface{}) (int64, error) {
+				return m.Patch(context.Background(), url, map[string]string{"name": "jim"}, out)
+			},
+			expectedMethod: "PATCH",
+			expectedBody:   map[string]interface{}{"name": "jim"},
+		},
+		{
+			name: "delete",
+			call: func(m *RestClient, url string, out interface{}) (int64, error) {
+				return m.Delete(context.Background(), url, out)
+			},
+			expectedMethod: "DELETE",
+		},
+	}
+
+	assertion := assert.New(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+
+			calls := 0
+			var method, path, apiKey string
+			var body map[string]interface{}
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				method = r.Method
+				path = r.URL.Path
+				apiKey = r.Header.Get("X-Api-Key")
+				b, _ := io.ReadAll(r.Body)
+				json.Unmarshal(b, &body)
+				if calls == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"message": "success"}`)
+			}))
+			defer svr.Close()
+
+			m := NewRestClient().
+				WithURL(svr.URL + "/default").
+				WithMethod("OPTIONS").
+				WithHeader(map[string]string{"X-Api-Key": "secret"}).
+				WithMaxAttempts(2)
+
+			var result map[string]interface{}
+			status, err := tc.call(m, svr.URL+"/orders", &result)
+			assertion.NoError(err)
+			assertion.Equal(int64(200), status)
+			assertion.Equal(map[string]interface{}{"message": "success"}, result)
+
+			assertion.Equal(2, calls)
+			assertion.Equal(tc.expectedMethod, method)
+			assertion.Equal("/orders", path)
+			assertion.Equal("secret", apiKey)
+			assertion.Equal(tc.expectedBody, body)
+
+			// the client itself is left untouched
+			assertion.Equal("OPTIONS", m.method)
+			assertion.Equal(svr.URL+"/default", m.url)
+		})
+	}
+}